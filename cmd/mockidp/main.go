@@ -0,0 +1,38 @@
+// Command mockidp runs the mock OpenID Connect provider from
+// internal/common/mockidp for exercising the social login flow locally. It
+// approves every authorization request without asking for credentials. The
+// user is the login_hint email, else MOCKIDP_EMAIL, else alice@example.com.
+//
+//	go run ./cmd/mockidp
+//	OIDC_PROVIDERS=mock
+//	OIDC_MOCK_ISSUER=http://localhost:9000
+//	OIDC_MOCK_CLIENT_ID=unbound
+package main
+
+import (
+	"log"
+	"net/http"
+	"os"
+
+	"unbound/internal/common/mockidp"
+)
+
+func main() {
+	addr := os.Getenv("MOCKIDP_ADDR")
+	if addr == "" {
+		addr = ":9000"
+	}
+	issuer := os.Getenv("MOCKIDP_ISSUER")
+	if issuer == "" {
+		issuer = "http://localhost" + addr
+	}
+
+	p, err := mockidp.New(issuer)
+	if err != nil {
+		log.Fatal(err)
+	}
+	p.Email = os.Getenv("MOCKIDP_EMAIL")
+
+	log.Printf("mock IdP listening on %s (issuer %s)", addr, issuer)
+	log.Fatal(http.ListenAndServe(addr, p))
+}
@@ -0,0 +1,180 @@
+// Command mocks3 is a minimal S3-compatible object store for exercising the
+// S3 upload path locally. It keeps objects in memory, accepts any bucket,
+// checks the SigV4 signature of writes and serves reads without auth, like
+// a public bucket. Buckets listed in MOCKS3_PRIVATE_BUCKETS (default
+// unbound-private) require signed reads too.
+//
+//	go run ./cmd/mocks3
+//	STORAGE_DRIVER=s3
+//	S3_ENDPOINT=http://localhost:9100
+//	S3_BUCKET=unbound
+//	S3_PRIVATE_BUCKET=unbound-private
+//	S3_ACCESS_KEY=mock
+//	S3_SECRET_KEY=mock-secret
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+)
+
+type object struct {
+	contentType string
+	data        []byte
+}
+
+type store struct {
+	accessKey string
+	secretKey string
+	private   map[string]bool
+	mu        sync.RWMutex
+	objects   map[string]object
+}
+
+func main() {
+	addr := os.Getenv("MOCKS3_ADDR")
+	if addr == "" {
+		addr = ":9100"
+	}
+	s := &store{
+		accessKey: envOr("MOCKS3_ACCESS_KEY", "mock"),
+		secretKey: envOr("MOCKS3_SECRET_KEY", "mock-secret"),
+		private:   make(map[string]bool),
+		objects:   make(map[string]object),
+	}
+	for _, b := range strings.Split(envOr("MOCKS3_PRIVATE_BUCKETS", "unbound-private"), ",") {
+		s.private[strings.TrimSpace(b)] = true
+	}
+
+	log.Printf("mock S3 listening on %s (access key %s)", addr, s.accessKey)
+	log.Fatal(http.ListenAndServe(addr, s))
+}
+
+func (s *store) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	path := r.URL.Path
+	if strings.Count(path, "/") < 2 {
+		http.Error(w, "expected /bucket/key", http.StatusBadRequest)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet, http.MethodHead:
+		bucket := strings.SplitN(strings.TrimPrefix(path, "/"), "/", 2)[0]
+		if s.private[bucket] && !s.verify(r, nil) {
+			http.Error(w, "AccessDenied", http.StatusForbidden)
+			return
+		}
+		s.mu.RLock()
+		obj, ok := s.objects[path]
+		s.mu.RUnlock()
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Write(obj.data)
+
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, "failed to read body", http.StatusBadRequest)
+			return
+		}
+		if !s.verify(r, body) {
+			http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+			return
+		}
+		s.mu.Lock()
+		s.objects[path] = object{contentType: r.Header.Get("Content-Type"), data: body}
+		s.mu.Unlock()
+		log.Printf("PUT %s (%d bytes)", path, len(body))
+
+	case http.MethodDelete:
+		if !s.verify(r, nil) {
+			http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+			return
+		}
+		s.mu.Lock()
+		delete(s.objects, path)
+		s.mu.Unlock()
+		log.Printf("DELETE %s", path)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+// verify recomputes the AWS Signature Version 4 of the request.
+func (s *store) verify(r *http.Request, body []byte) bool {
+	var credential, signedHeaders, signature string
+	for _, part := range strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "), ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "Credential":
+			credential = v
+		case "SignedHeaders":
+			signedHeaders = v
+		case "Signature":
+			signature = v
+		}
+	}
+	scopeParts := strings.SplitN(credential, "/", 2)
+	if len(scopeParts) != 2 || scopeParts[0] != s.accessKey {
+		return false
+	}
+	scope := scopeParts[1]
+	fields := strings.Split(scope, "/")
+	if len(fields) != 4 {
+		return false
+	}
+
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash != sha256Hex(body) {
+		return false
+	}
+
+	var headers strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonical := strings.Join([]string{
+		r.Method, r.URL.EscapedPath(), r.URL.RawQuery, headers.String(), signedHeaders, payloadHash,
+	}, "\n")
+	toSign := "AWS4-HMAC-SHA256\n" + r.Header.Get("X-Amz-Date") + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	key := []byte("AWS4" + s.secretKey)
+	for _, f := range fields {
+		key = hmacSHA256(key, f)
+	}
+	return hmac.Equal([]byte(hex.EncodeToString(hmacSHA256(key, toSign))), []byte(signature))
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -2,14 +2,18 @@ package main
 
 import (
 	"log"
+	"strings"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/contrib/websocket"
 	"github.com/joho/godotenv"
 
+	"unbound/internal/account"
+	"unbound/internal/admin"
 	"unbound/internal/auth"
 	"unbound/internal/common/db"
 	"unbound/internal/common/middleware"
+	"unbound/internal/common/storage"
 	"unbound/internal/post"
 	"unbound/internal/search"
 	"unbound/internal/user"
@@ -20,7 +24,20 @@ import (
 func main() {
 	_ = godotenv.Load()
 
-	app := fiber.New()
+	app := fiber.New(fiber.Config{BodyLimit: 6 << 20})
+
+	// Uploaded files are served as they are, ahead of the JSON wrapper.
+	// Data exports live in the private store and never under /media, but
+	// exports/ is refused there all the same.
+	store := storage.FromEnv()
+	privateStore := storage.PrivateFromEnv()
+	if local, ok := store.(*storage.LocalStore); ok {
+		app.Static(local.Path(), local.Dir, fiber.Static{
+			Next: func(c *fiber.Ctx) bool {
+				return strings.HasPrefix(c.Path(), local.Path()+"/exports/")
+			},
+		})
+	}
 
 	app.Use(middleware.JSONResponseMiddleware)
 
@@ -33,20 +50,38 @@ func main() {
 
 	database := db.Connect()
 	authSvc := auth.NewAuthService(database)
+	go authSvc.Revocations.Run()
+	if err := authSvc.EnsureRoles(); err != nil {
+		log.Fatalf("❌ Failed to set up roles: %v", err)
+	}
 
 	auth.RegisterRoutes(app, database, authSvc)
+	auth.RegisterSessionRoutes(app, authSvc, middleware.SessionProtected(authSvc))
+	auth.RegisterVerificationRoutes(app, authSvc, middleware.SessionProtected(authSvc))
+	auth.RegisterMFARoutes(app, authSvc, middleware.SessionProtected(authSvc))
+	auth.RegisterOIDCRoutes(app, authSvc, middleware.SessionProtected(authSvc))
+	auth.RegisterPATRoutes(app, authSvc, middleware.SessionProtected(authSvc))
 	user.RegisterRoutes(app, database)
-	user.RegisterProfileRoutes(app, database)
+	user.RegisterProfileRoutes(app, database, authSvc, store)
 	user.RegisterFollowRoutes(app, database, authSvc)
+	user.RegisterFollowRequestRoutes(app, database, authSvc)
+	user.RegisterBlockRoutes(app, database, authSvc)
+	user.RegisterSuggestionRoutes(app, database, authSvc)
+	user.RegisterListRoutes(app, database, authSvc)
 	post.RegisterRoutes(app, database, authSvc)
 	post.RegisterLikeRoutes(app, database, authSvc)
 	post.RegisterCommentRoutes(app, database, authSvc)
 	post.RegisterFeedRoutes(app, database, authSvc)
 	post.RegisterEditRoutes(app, database, authSvc)
 	post.RegisterCommentEditRoutes(app, database, authSvc)
-	search.RegisterSearchRoutes(app, database)
+	search.RegisterSearchRoutes(app, database, authSvc)
 	notification.RegisterRoutes(app, database, authSvc)
 	chat.RegisterChatRoutes(app, database, authSvc)
+	admin.RegisterRoutes(app, database, authSvc)
+	exporter := account.NewExporter(database, store, privateStore, authSvc)
+	account.RegisterRoutes(app, database, authSvc, exporter)
+	go account.NewPurger(database, store, privateStore).Run()
+	go exporter.Run()
 
 	app.Get("/", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{
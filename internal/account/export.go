@@ -0,0 +1,221 @@
+package account
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"unbound/internal/auth"
+	"unbound/internal/common/mailer"
+	"unbound/internal/common/storage"
+)
+
+const (
+	exportInterval    = time.Minute
+	exportTTL         = 48 * time.Hour
+	exportCooldown    = 24 * time.Hour
+	exportHeartbeat   = time.Minute
+	exportStaleAfter  = 5 * time.Minute
+	exportMaxAttempts = 3
+)
+
+// errExportClaimLost means another worker took the job over, e.g. after this
+// one missed its heartbeats. The job is left to that worker.
+var errExportClaimLost = errors.New("export job was claimed by another worker")
+
+// Exporter builds data export archives in the background, one at a time.
+// Jobs are claimed with SKIP LOCKED, so several instances can run it.
+// Archives go to the Private store; Store is only read for the user's
+// uploaded images.
+type Exporter struct {
+	DB      *gorm.DB
+	Store   storage.BlobStore
+	Private storage.BlobStore
+	Auth    *auth.AuthService
+	wake    chan struct{}
+}
+
+func NewExporter(db *gorm.DB, store, private storage.BlobStore, authSvc *auth.AuthService) *Exporter {
+	return &Exporter{DB: db, Store: store, Private: private, Auth: authSvc, wake: make(chan struct{}, 1)}
+}
+
+func (e *Exporter) Run() {
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	for {
+		e.expire()
+		for e.next() {
+		}
+		select {
+		case <-ticker.C:
+		case <-e.wake:
+		}
+	}
+}
+
+// Wake makes Run look for pending jobs now instead of at the next tick.
+func (e *Exporter) Wake() {
+	select {
+	case e.wake <- struct{}{}:
+	default:
+	}
+}
+
+// next claims and builds one pending export. It reports whether there was
+// one, so Run can drain the queue. A running job is taken over only once its
+// worker stopped sending heartbeats, and given up after exportMaxAttempts.
+func (e *Exporter) next() bool {
+	var job DataExport
+	err := e.DB.Transaction(func(tx *gorm.DB) error {
+		stale := time.Now().Add(-exportStaleAfter)
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Where("status = ? OR (status = ? AND COALESCE(heartbeat_at, started_at) < ?)", ExportPending, ExportRunning, stale).
+			Order("id").Limit(1).Find(&job).Error; err != nil || job.ID == 0 {
+			return err
+		}
+		if job.Attempts >= exportMaxAttempts {
+			job.Status = ExportFailed
+			return tx.Model(&job).Update("status", ExportFailed).Error
+		}
+		// Postgres keeps microseconds; the claim is matched on started_at.
+		now := time.Now().Truncate(time.Microsecond)
+		job.StartedAt = &now
+		return tx.Model(&job).Updates(map[string]interface{}{
+			"status":       ExportRunning,
+			"started_at":   now,
+			"heartbeat_at": now,
+			"attempts":     gorm.Expr("attempts + 1"),
+		}).Error
+	})
+	if err != nil {
+		log.Printf("⚠️ failed to claim data export: %v", err)
+		return false
+	}
+	if job.ID == 0 {
+		return false
+	}
+	if job.Status == ExportFailed {
+		log.Printf("❌ data export %d for user %d gave up after %d attempts", job.ID, job.UserID, job.Attempts)
+		return true
+	}
+
+	stop := make(chan struct{})
+	go e.heartbeat(&job, stop)
+	err = e.build(&job)
+	close(stop)
+	if err == errExportClaimLost {
+		log.Printf("⚠️ data export %d was taken over by another worker", job.ID)
+	} else if err != nil {
+		log.Printf("❌ data export %d for user %d failed: %v", job.ID, job.UserID, err)
+		e.claimed(&job).Update("status", ExportFailed)
+	}
+	return true
+}
+
+// claimed scopes an update to the job as long as this worker still holds it.
+func (e *Exporter) claimed(job *DataExport) *gorm.DB {
+	return e.DB.Model(&DataExport{}).
+		Where("id = ? AND status = ? AND started_at = ?", job.ID, ExportRunning, *job.StartedAt)
+}
+
+// heartbeat tells other workers the job is still being built until stop is
+// closed.
+func (e *Exporter) heartbeat(job *DataExport, stop <-chan struct{}) {
+	ticker := time.NewTicker(exportHeartbeat)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			if err := e.claimed(job).Update("heartbeat_at", time.Now()).Error; err != nil {
+				log.Printf("⚠️ failed to record heartbeat of data export %d: %v", job.ID, err)
+			}
+		}
+	}
+}
+
+func (e *Exporter) build(job *DataExport) error {
+	var u auth.User
+	if err := e.DB.Select("id", "username", "email").First(&u, job.UserID).Error; err != nil {
+		return err
+	}
+
+	archive, err := buildArchive(e.DB, e.Store, job.UserID)
+	if err != nil {
+		return err
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return err
+	}
+	suffix, err := randomHex(16)
+	if err != nil {
+		return err
+	}
+	key := fmt.Sprintf("exports/%d/%s.zip", job.UserID, suffix)
+	if err := e.Private.Put(key, "application/zip", archive); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	expiresAt := now.Add(exportTTL)
+	res := e.claimed(job).Updates(map[string]interface{}{
+		"status":       ExportReady,
+		"blob_key":     key,
+		"token_hash":   e.Auth.HashToken(token),
+		"size":         len(archive),
+		"completed_at": now,
+		"expires_at":   expiresAt,
+	})
+	if res.Error != nil || res.RowsAffected == 0 {
+		if err := e.Private.Delete(key); err != nil {
+			log.Printf("⚠️ failed to delete %s: %v", key, err)
+		}
+		if res.Error != nil {
+			return res.Error
+		}
+		return errExportClaimLost
+	}
+
+	err = e.Auth.Mailer.Send(mailer.Message{
+		To:      u.Email,
+		Subject: "Data akun Unbound kamu siap diunduh",
+		Body: fmt.Sprintf("Halo %s,\n\nSalinan data akun kamu sudah siap. Unduh lewat link berikut:\n%s/exports/download?token=%s\n\nLink berlaku sampai %s. Jangan bagikan link ini ke siapa pun.\n",
+			u.Username, e.Auth.AppURL, token, expiresAt.Format(time.RFC1123)),
+	})
+	if err != nil {
+		log.Printf("⚠️ failed to send data export email to user %d: %v", u.ID, err)
+	}
+	return nil
+}
+
+// expire deletes archives whose download window is over.
+func (e *Exporter) expire() {
+	var jobs []DataExport
+	if err := e.DB.Where("status = ? AND expires_at <= ?", ExportReady, time.Now()).Find(&jobs).Error; err != nil {
+		log.Printf("⚠️ failed to look up expired data exports: %v", err)
+		return
+	}
+	for _, job := range jobs {
+		if err := e.Private.Delete(job.BlobKey); err != nil {
+			log.Printf("⚠️ failed to delete %s: %v", job.BlobKey, err)
+			continue
+		}
+		e.DB.Model(&job).Updates(map[string]interface{}{"status": ExportExpired, "blob_key": "", "token_hash": ""})
+	}
+}
+
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,317 @@
+package account
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"path"
+	"reflect"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"unbound/internal/common/storage"
+	"unbound/internal/user"
+)
+
+const exportReadme = `Unbound data export
+
+Every file is JSON; tables also come as CSV with the same columns.
+
+account.json        account details and previous usernames
+profile.json        profile and privacy settings
+posts               your posts, including deleted ones (deleted_at)
+comments            your comments, including deleted ones
+likes               posts you liked
+followers           accounts following you
+following           accounts you follow
+messages            messages in your chats, from both sides
+notifications       notifications you received
+blocks.json         accounts you blocked
+mutes.json          accounts you muted
+lists.json          your lists and their members
+media/              your uploaded profile images
+`
+
+type exportAccount struct {
+	ID                uint       `json:"id"`
+	Username          string     `json:"username"`
+	Email             string     `json:"email"`
+	Role              string     `json:"role"`
+	CreatedAt         time.Time  `json:"created_at"`
+	EmailVerifiedAt   *time.Time `json:"email_verified_at"`
+	UsernameChangedAt *time.Time `json:"username_changed_at"`
+	TOTPEnabled       bool       `json:"two_factor_enabled"`
+	PreviousUsernames []string   `json:"previous_usernames" gorm:"-"`
+}
+
+type exportProfile struct {
+	DisplayName  string    `json:"display_name"`
+	Bio          string    `json:"bio"`
+	Website      string    `json:"website"`
+	Location     string    `json:"location"`
+	Pronouns     string    `json:"pronouns"`
+	AvatarURL    string    `json:"avatar_url"`
+	BannerURL    string    `json:"banner_url"`
+	IsPrivate    bool      `json:"is_private"`
+	PublicFields *string   `json:"public_fields"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+type exportPost struct {
+	ID        uint       `json:"id"`
+	Content   string     `json:"content"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+type exportComment struct {
+	ID        uint       `json:"id"`
+	PostID    uint       `json:"post_id"`
+	Content   string     `json:"content"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	DeletedAt *time.Time `json:"deleted_at"`
+}
+
+type exportLike struct {
+	PostID    uint      `json:"post_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportAccountRef struct {
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportMessage struct {
+	ID        uint       `json:"id"`
+	ChatID    uint       `json:"chat_id"`
+	Sender    string     `json:"sender"`
+	Content   string     `json:"content"`
+	Status    string     `json:"status"`
+	ReadAt    *time.Time `json:"read_at"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+type exportNotification struct {
+	ID        uint      `json:"id"`
+	Type      string    `json:"type"`
+	Actor     string    `json:"actor"`
+	PostID    *uint     `json:"post_id"`
+	Message   string    `json:"message"`
+	IsRead    bool      `json:"is_read"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportList struct {
+	ID          uint      `json:"id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	IsPrivate   bool      `json:"is_private"`
+	CreatedAt   time.Time `json:"created_at"`
+	Members     []string  `json:"members" gorm:"-"`
+}
+
+// maxExportSize caps the archive, which is built in memory before it is
+// handed to the store.
+const maxExportSize = 256 << 20
+
+var errExportTooLarge = fmt.Errorf("archive is larger than %d MiB", maxExportSize>>20)
+
+// cappedBuffer is a bytes.Buffer that refuses to grow past limit.
+type cappedBuffer struct {
+	bytes.Buffer
+	limit int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if b.Len()+len(p) > b.limit {
+		return 0, errExportTooLarge
+	}
+	return b.Buffer.Write(p)
+}
+
+// buildArchive collects everything stored about the user into a zip file.
+// Other accounts only appear by username.
+func buildArchive(db *gorm.DB, store storage.BlobStore, userID uint) ([]byte, error) {
+	buf := cappedBuffer{limit: maxExportSize}
+	zw := zip.NewWriter(&buf)
+
+	var acc exportAccount
+	if err := db.Raw(`
+		SELECT id, username, email, role, created_at, email_verified_at, username_changed_at,
+			totp_enabled_at IS NOT NULL AS totp_enabled
+		FROM users WHERE id = ?
+	`, userID).Scan(&acc).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Raw(`SELECT old_username FROM username_histories WHERE user_id = ? ORDER BY created_at`, userID).
+		Scan(&acc.PreviousUsernames).Error; err != nil {
+		return nil, err
+	}
+
+	var profile user.Profile
+	if err := db.Where("user_id = ?", userID).Limit(1).Find(&profile).Error; err != nil {
+		return nil, err
+	}
+
+	var posts []exportPost
+	var comments []exportComment
+	var likes []exportLike
+	var followers, following, blocks, mutes []exportAccountRef
+	var messages []exportMessage
+	var notifications []exportNotification
+	var lists []exportList
+	queries := []struct {
+		dst   interface{}
+		query string
+		args  []interface{}
+	}{
+		{&posts, `SELECT id, content, created_at, updated_at, deleted_at FROM posts WHERE user_id = ? ORDER BY id`, []interface{}{userID}},
+		{&comments, `SELECT id, post_id, content, created_at, updated_at, deleted_at FROM comments WHERE user_id = ? ORDER BY id`, []interface{}{userID}},
+		{&likes, `SELECT post_id, created_at FROM likes WHERE user_id = ? AND deleted_at IS NULL ORDER BY id`, []interface{}{userID}},
+		{&followers, `SELECT u.username, f.created_at FROM follows f JOIN users u ON u.id = f.follower_id
+			WHERE f.following_id = ? AND f.deleted_at IS NULL ORDER BY f.id`, []interface{}{userID}},
+		{&following, `SELECT u.username, f.created_at FROM follows f JOIN users u ON u.id = f.following_id
+			WHERE f.follower_id = ? AND f.deleted_at IS NULL ORDER BY f.id`, []interface{}{userID}},
+		{&blocks, `SELECT u.username, b.created_at FROM blocks b JOIN users u ON u.id = b.blocked_id
+			WHERE b.blocker_id = ? ORDER BY b.id`, []interface{}{userID}},
+		{&mutes, `SELECT u.username, m.created_at FROM mutes m JOIN users u ON u.id = m.muted_id
+			WHERE m.muter_id = ? ORDER BY m.id`, []interface{}{userID}},
+		{&messages, `SELECT m.id, m.chat_id, u.username AS sender, m.content, m.status, m.read_at, m.created_at
+			FROM messages m JOIN chats c ON c.id = m.chat_id JOIN users u ON u.id = m.sender_id
+			WHERE c.user1_id = ? OR c.user2_id = ? ORDER BY m.chat_id, m.id`, []interface{}{userID, userID}},
+		{&notifications, `SELECT n.id, n.type, COALESCE(u.username, '') AS actor, n.post_id, n.message, n.is_read, n.created_at
+			FROM notifications n LEFT JOIN users u ON u.id = n.actor_id
+			WHERE n.user_id = ? AND n.deleted_at IS NULL ORDER BY n.id`, []interface{}{userID}},
+		{&lists, `SELECT id, name, description, is_private, created_at FROM lists WHERE owner_id = ? ORDER BY id`, []interface{}{userID}},
+	}
+	for _, q := range queries {
+		if err := db.Raw(q.query, q.args...).Scan(q.dst).Error; err != nil {
+			return nil, err
+		}
+	}
+	for i := range lists {
+		if err := db.Raw(`SELECT u.username FROM list_members lm JOIN users u ON u.id = lm.user_id
+			WHERE lm.list_id = ? ORDER BY lm.id`, lists[i].ID).Scan(&lists[i].Members).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	files := []struct {
+		name  string
+		data  interface{}
+		table bool
+	}{
+		{"account", acc, false},
+		{"profile", exportProfile{
+			DisplayName: profile.DisplayName, Bio: profile.Bio, Website: profile.Website,
+			Location: profile.Location, Pronouns: profile.Pronouns, AvatarURL: profile.AvatarURL,
+			BannerURL: profile.BannerURL, IsPrivate: profile.IsPrivate, PublicFields: profile.PublicFields,
+			UpdatedAt: profile.UpdatedAt,
+		}, false},
+		{"posts", posts, true},
+		{"comments", comments, true},
+		{"likes", likes, true},
+		{"followers", followers, true},
+		{"following", following, true},
+		{"messages", messages, true},
+		{"notifications", notifications, true},
+		{"blocks", blocks, false},
+		{"mutes", mutes, false},
+		{"lists", lists, false},
+	}
+	if err := writeZipFile(zw, "README.txt", []byte(exportReadme)); err != nil {
+		return nil, err
+	}
+	for _, f := range files {
+		data, err := json.MarshalIndent(f.data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		if err := writeZipFile(zw, f.name+".json", data); err != nil {
+			return nil, err
+		}
+		if !f.table {
+			continue
+		}
+		data, err = toCSV(f.data)
+		if err != nil {
+			return nil, err
+		}
+		if err := writeZipFile(zw, f.name+".csv", data); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, key := range strings.Fields(profile.AvatarKeys + " " + profile.BannerKeys) {
+		data, err := store.Get(key)
+		if err != nil {
+			return nil, fmt.Errorf("read %s: %w", key, err)
+		}
+		if err := writeZipFile(zw, "media/"+path.Base(key), data); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeZipFile(zw *zip.Writer, name string, data []byte) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// toCSV writes a slice of flat structs as CSV, using the json names of the
+// fields as the header.
+func toCSV(rows interface{}) ([]byte, error) {
+	v := reflect.ValueOf(rows)
+	t := v.Type().Elem()
+
+	var header []string
+	for i := 0; i < t.NumField(); i++ {
+		header = append(header, strings.Split(t.Field(i).Tag.Get("json"), ",")[0])
+	}
+
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+	for i := 0; i < v.Len(); i++ {
+		row := v.Index(i)
+		record := make([]string, t.NumField())
+		for j := range record {
+			record[j] = csvValue(row.Field(j))
+		}
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+func csvValue(f reflect.Value) string {
+	if f.Kind() == reflect.Ptr {
+		if f.IsNil() {
+			return ""
+		}
+		f = f.Elem()
+	}
+	if t, ok := f.Interface().(time.Time); ok {
+		return t.UTC().Format(time.RFC3339)
+	}
+	return fmt.Sprint(f.Interface())
+}
@@ -0,0 +1,93 @@
+package account
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// sendExport streams a ready archive as a file download. This is the only
+// way out of the private store.
+func sendExport(c *fiber.Ctx, exp *Exporter, job DataExport) error {
+	if job.Status != ExportReady || job.ExpiresAt == nil || time.Now().After(*job.ExpiresAt) {
+		return fiber.NewError(fiber.StatusGone, "export is not available anymore")
+	}
+	data, err := exp.Private.Get(job.BlobKey)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to read export")
+	}
+	c.Set(fiber.HeaderContentType, "application/zip")
+	c.Set(fiber.HeaderContentDisposition,
+		fmt.Sprintf(`attachment; filename="unbound-export-%s.zip"`, job.CompletedAt.Format("2006-01-02")))
+	c.Set(fiber.HeaderCacheControl, "private, no-store")
+	return c.Send(data)
+}
+
+func registerExportRoutes(app *fiber.App, r fiber.Router, exp *Exporter, protected fiber.Handler) {
+	db := exp.DB
+
+	r.Post("/export", protected, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+
+		var last DataExport
+		if err := db.Where("user_id = ? AND status <> ?", userID, ExportFailed).
+			Order("created_at DESC").Limit(1).Find(&last).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to request export")
+		}
+		if last.ID != 0 && (last.Status == ExportPending || last.Status == ExportRunning) {
+			return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+				"success": true,
+				"data":    last,
+			})
+		}
+		if last.ID != 0 && time.Since(last.CreatedAt) < exportCooldown {
+			return fiber.NewError(fiber.StatusTooManyRequests, "you can request one export per day")
+		}
+
+		job := DataExport{UserID: userID, Status: ExportPending}
+		if err := db.Create(&job).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to request export")
+		}
+		exp.Wake()
+
+		return c.Status(fiber.StatusAccepted).JSON(fiber.Map{
+			"success": true,
+			"message": "Export requested, you will get an email when it is ready",
+			"data":    job,
+		})
+	})
+
+	r.Get("/export", protected, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+
+		var jobs []DataExport
+		if err := db.Where("user_id = ?", userID).Order("created_at DESC").Limit(10).Find(&jobs).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch exports")
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    jobs,
+		})
+	})
+
+	// The mailed link works without a session, so it can be opened in a
+	// browser; the token is only valid while the export is.
+	app.Get("/exports/download", func(c *fiber.Ctx) error {
+		token := c.Query("token")
+		if token == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "token required")
+		}
+
+		var job DataExport
+		if err := db.Where("token_hash = ?", exp.Auth.HashToken(token)).First(&job).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fiber.NewError(fiber.StatusNotFound, "export not found")
+			}
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch export")
+		}
+		return sendExport(c, exp, job)
+	})
+}
@@ -0,0 +1,29 @@
+package account
+
+import "time"
+
+const (
+	ExportPending = "pending"
+	ExportRunning = "running"
+	ExportReady   = "ready"
+	ExportFailed  = "failed"
+	ExportExpired = "expired"
+)
+
+// DataExport is a "download my data" request. The archive is built in the
+// background into the private store; once ready it can be downloaded until
+// ExpiresAt with the token mailed to the user.
+type DataExport struct {
+	ID          uint       `gorm:"primaryKey" json:"id"`
+	UserID      uint       `gorm:"index;not null" json:"-"`
+	Status      string     `gorm:"size:16;not null;index" json:"status"`
+	BlobKey     string     `gorm:"size:255" json:"-"`
+	TokenHash   string     `gorm:"size:64;index" json:"-"`
+	Size        int64      `json:"size,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	StartedAt   *time.Time `json:"-"`
+	HeartbeatAt *time.Time `json:"-"`
+	Attempts    int        `gorm:"not null;default:0" json:"-"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+}
@@ -0,0 +1,89 @@
+package account
+
+import (
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"unbound/internal/auth"
+	"unbound/internal/common/middleware"
+)
+
+const defaultDeletionGraceDays = 30
+
+func deletionGracePeriod() time.Duration {
+	days, err := strconv.Atoi(os.Getenv("ACCOUNT_DELETION_GRACE_DAYS"))
+	if err != nil || days < 0 {
+		days = defaultDeletionGraceDays
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+func RegisterRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService, exp *Exporter) {
+	r := app.Group("/users/me")
+	protected := middleware.SessionProtected(authSvc)
+
+	registerSettingsRoutes(r, authSvc, protected)
+	registerExportRoutes(app, r, exp, protected)
+
+	r.Delete("/", protected, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		var body struct {
+			Password string `json:"password"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Password == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "password required")
+		}
+		if err := authSvc.CheckPassword(userID, body.Password); err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "invalid password")
+		}
+
+		now := time.Now()
+		purgeAt := now.Add(deletionGracePeriod())
+		if err := db.Model(&auth.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"deactivated_at":        now,
+			"deletion_scheduled_at": purgeAt,
+		}).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to schedule deletion")
+		}
+
+		if err := authSvc.RevokeAllSessions(userID); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to revoke sessions")
+		}
+
+		return c.JSON(fiber.Map{
+			"success":       true,
+			"message":       "Account scheduled for deletion. Login again before the date to cancel.",
+			"scheduled_for": purgeAt,
+		})
+	})
+
+	r.Post("/deactivate", protected, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		var body struct {
+			Password string `json:"password"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Password == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "password required")
+		}
+		if err := authSvc.CheckPassword(userID, body.Password); err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, "invalid password")
+		}
+
+		if err := db.Model(&auth.User{}).Where("id = ?", userID).
+			Update("deactivated_at", time.Now()).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to deactivate account")
+		}
+
+		if err := authSvc.RevokeAllSessions(userID); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to revoke sessions")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Account deactivated. Login again to reactivate it.",
+		})
+	})
+}
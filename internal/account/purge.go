@@ -0,0 +1,142 @@
+package account
+
+import (
+	"log"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"unbound/internal/auth"
+	"unbound/internal/chat"
+	"unbound/internal/common/storage"
+	"unbound/internal/notification"
+	"unbound/internal/post"
+	"unbound/internal/user"
+)
+
+const purgeInterval = time.Hour
+
+// Purger hard-deletes accounts whose deletion grace period is over. The
+// database has no foreign keys, so every table holding user data has to be
+// cleaned up here explicitly. Token revocations and the user's token cutoff
+// are kept: access tokens are checked without a database lookup, so they are
+// what keeps the purged user's outstanding tokens rejected until they expire.
+type Purger struct {
+	DB      *gorm.DB
+	Store   storage.BlobStore
+	Private storage.BlobStore
+}
+
+func NewPurger(db *gorm.DB, store, private storage.BlobStore) *Purger {
+	return &Purger{DB: db, Store: store, Private: private}
+}
+
+func (p *Purger) Run() {
+	p.purgeDue()
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		p.purgeDue()
+	}
+}
+
+func (p *Purger) purgeDue() {
+	var ids []uint
+	if err := p.DB.Model(&auth.User{}).
+		Where("deletion_scheduled_at IS NOT NULL AND deletion_scheduled_at <= ?", time.Now()).
+		Pluck("id", &ids).Error; err != nil {
+		log.Printf("⚠️ failed to look up accounts to purge: %v", err)
+		return
+	}
+
+	for _, id := range ids {
+		if err := p.PurgeUser(id); err != nil {
+			log.Printf("❌ failed to purge user %d: %v", id, err)
+			continue
+		}
+		log.Printf("🗑️ purged user %d", id)
+	}
+}
+
+func (p *Purger) PurgeUser(userID uint) error {
+	// Uploaded files and export archives are removed once the rows pointing
+	// at them are gone.
+	var profile user.Profile
+	if err := p.DB.Where("user_id = ?", userID).Limit(1).Find(&profile).Error; err != nil {
+		return err
+	}
+	var exports []string
+	if err := p.DB.Model(&DataExport{}).Where("user_id = ? AND blob_key <> ''", userID).
+		Pluck("blob_key", &exports).Error; err != nil {
+		return err
+	}
+	if err := p.purgeRows(userID); err != nil {
+		return err
+	}
+	p.deleteBlobs(p.Store, userID, strings.Fields(profile.AvatarKeys+" "+profile.BannerKeys))
+	p.deleteBlobs(p.Private, userID, exports)
+	return nil
+}
+
+func (p *Purger) deleteBlobs(store storage.BlobStore, userID uint, keys []string) {
+	for _, key := range keys {
+		if err := store.Delete(key); err != nil {
+			log.Printf("⚠️ failed to delete %s of user %d: %v", key, userID, err)
+		}
+	}
+}
+
+func (p *Purger) purgeRows(userID uint) error {
+	return p.DB.Transaction(func(tx *gorm.DB) error {
+		var u auth.User
+		if err := tx.Unscoped().Select("id", "email").First(&u, userID).Error; err != nil {
+			return err
+		}
+
+		tx = tx.Unscoped()
+		ownPosts := tx.Model(&post.Post{}).Select("id").Where("user_id = ?", userID)
+		ownLists := tx.Model(&user.List{}).Select("id").Where("owner_id = ?", userID)
+		ownChats := tx.Model(&chat.Chat{}).Select("id").Where("user1_id = ? OR user2_id = ?", userID, userID)
+
+		steps := []struct {
+			model interface{}
+			query string
+			args  []interface{}
+		}{
+			{&post.Like{}, "user_id = ? OR post_id IN (?)", []interface{}{userID, ownPosts}},
+			{&post.Comment{}, "user_id = ? OR post_id IN (?)", []interface{}{userID, ownPosts}},
+			{&notification.Notification{}, "user_id = ? OR actor_id = ? OR post_id IN (?)", []interface{}{userID, userID, ownPosts}},
+			{&post.Post{}, "user_id = ?", []interface{}{userID}},
+			{&user.Follow{}, "follower_id = ? OR following_id = ?", []interface{}{userID, userID}},
+			{&user.Profile{}, "user_id = ?", []interface{}{userID}},
+			{&user.FollowRequest{}, "requester_id = ? OR target_id = ?", []interface{}{userID, userID}},
+			{&user.Block{}, "blocker_id = ? OR blocked_id = ?", []interface{}{userID, userID}},
+			{&user.Mute{}, "muter_id = ? OR muted_id = ?", []interface{}{userID, userID}},
+			{&user.SuggestionDismissal{}, "user_id = ? OR dismissed_id = ?", []interface{}{userID, userID}},
+			{&user.ListMember{}, "user_id = ? OR list_id IN (?)", []interface{}{userID, ownLists}},
+			{&user.List{}, "owner_id = ?", []interface{}{userID}},
+			{&chat.Message{}, "sender_id = ? OR chat_id IN (?)", []interface{}{userID, ownChats}},
+			{&chat.Chat{}, "user1_id = ? OR user2_id = ?", []interface{}{userID, userID}},
+
+			{&auth.RefreshToken{}, "user_id = ?", []interface{}{userID}},
+			{&auth.Session{}, "user_id = ?", []interface{}{userID}},
+			{&auth.EmailVerificationToken{}, "user_id = ?", []interface{}{userID}},
+			{&auth.PasswordResetToken{}, "user_id = ?", []interface{}{userID}},
+			{&auth.RecoveryCode{}, "user_id = ?", []interface{}{userID}},
+			{&auth.MFAChallenge{}, "user_id = ?", []interface{}{userID}},
+			{&auth.ExternalIdentity{}, "user_id = ?", []interface{}{userID}},
+			{&auth.OIDCAuthRequest{}, "link_user_id = ?", []interface{}{userID}},
+			{&auth.PersonalAccessToken{}, "user_id = ?", []interface{}{userID}},
+			{&auth.UsernameHistory{}, "user_id = ?", []interface{}{userID}},
+			{&DataExport{}, "user_id = ?", []interface{}{userID}},
+			{&auth.LoginAttempt{}, "key = ?", []interface{}{"login:account:" + auth.AccountKey(u.Email)}},
+		}
+		for _, s := range steps {
+			if err := tx.Where(s.query, s.args...).Delete(s.model).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Delete(&auth.User{}, userID).Error
+	})
+}
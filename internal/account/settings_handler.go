@@ -0,0 +1,84 @@
+package account
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"unbound/internal/auth"
+)
+
+func registerSettingsRoutes(r fiber.Router, authSvc *auth.AuthService, protected fiber.Handler) {
+	r.Patch("/password", protected, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		current, _ := c.Locals("sessionID").(string)
+		var body struct {
+			CurrentPassword string `json:"current_password"`
+			NewPassword     string `json:"new_password"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.CurrentPassword == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "current_password and new_password required")
+		}
+
+		if err := authSvc.ChangePassword(userID, current, body.CurrentPassword, body.NewPassword); err != nil {
+			return settingsError(err)
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Password updated, other sessions have been logged out",
+		})
+	})
+
+	r.Patch("/email", protected, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		var body struct {
+			Email    string `json:"email"`
+			Password string `json:"password"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Email == "" || body.Password == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "email and password required")
+		}
+
+		if err := authSvc.ChangeEmail(userID, body.Password, body.Email); err != nil {
+			return settingsError(err)
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Verification link sent to the new address, the email changes once it is verified",
+		})
+	})
+
+	r.Patch("/username", protected, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		var body struct {
+			Username string `json:"username"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Username == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "username required")
+		}
+
+		u, err := authSvc.ChangeUsername(userID, body.Username)
+		if err != nil {
+			return settingsError(err)
+		}
+
+		return c.JSON(fiber.Map{
+			"success":  true,
+			"username": u.Username,
+		})
+	})
+}
+
+func settingsError(err error) error {
+	switch err {
+	case auth.ErrInvalidCredentials:
+		return fiber.NewError(fiber.StatusUnauthorized, "invalid password")
+	case auth.ErrEmailTaken, auth.ErrUsernameTaken, auth.ErrConfusableUsername:
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case auth.ErrUsernameChangeTooSoon:
+		return fiber.NewError(fiber.StatusTooManyRequests, err.Error())
+	case auth.ErrPasswordTooShort, auth.ErrInvalidEmail, auth.ErrInvalidUsername,
+		auth.ErrReservedUsername, auth.ErrUnchanged:
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return fiber.NewError(fiber.StatusInternalServerError, "failed to update account")
+}
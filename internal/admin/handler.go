@@ -0,0 +1,162 @@
+package admin
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"unbound/internal/auth"
+	"unbound/internal/common/middleware"
+)
+
+type roleReq struct {
+	Name        string   `json:"name"`
+	Permissions []string `json:"permissions"`
+}
+
+func RegisterRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService) {
+	r := app.Group("/admin", middleware.SessionProtected(authSvc))
+	manageRoles := middleware.RequirePermission(authSvc, auth.PermRolesManage)
+
+	r.Get("/roles", manageRoles, func(c *fiber.Ctx) error {
+		roles, err := authSvc.ListRoles()
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch roles")
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    roles,
+		})
+	})
+
+	r.Post("/roles", manageRoles, func(c *fiber.Ctx) error {
+		var body roleReq
+		if err := c.BodyParser(&body); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid body")
+		}
+
+		role, err := authSvc.CreateRole(c.Locals("userID").(uint), body.Name, body.Permissions)
+		if err != nil {
+			return roleError(err)
+		}
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"success": true,
+			"data":    role,
+		})
+	})
+
+	r.Put("/roles/:name", manageRoles, func(c *fiber.Ctx) error {
+		var body roleReq
+		if err := c.BodyParser(&body); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid body")
+		}
+
+		role, err := authSvc.UpdateRolePermissions(c.Locals("userID").(uint), c.Params("name"), body.Permissions)
+		if err != nil {
+			return roleError(err)
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    role,
+		})
+	})
+
+	r.Delete("/roles/:name", manageRoles, func(c *fiber.Ctx) error {
+		if err := authSvc.DeleteRole(c.Params("name")); err != nil {
+			return roleError(err)
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "role deleted",
+		})
+	})
+
+	r.Get("/users", middleware.RequirePermission(authSvc, auth.PermUsersRead), func(c *fiber.Ctx) error {
+		limit, _ := strconv.Atoi(c.Query("limit", "50"))
+		offset, _ := strconv.Atoi(c.Query("offset", "0"))
+		if limit <= 0 || limit > 200 {
+			limit = 50
+		}
+
+		var users []struct {
+			ID        uint   `json:"id"`
+			Username  string `json:"username"`
+			Email     string `json:"email"`
+			Role      string `json:"role"`
+			CreatedAt string `json:"created_at"`
+		}
+		q := db.Model(&auth.User{}).Select("id, username, email, role, created_at")
+		if role := c.Query("role"); role != "" {
+			q = q.Where("role = ?", role)
+		}
+		if query := c.Query("query"); query != "" {
+			q = q.Where("username ILIKE ? OR email ILIKE ?", "%"+query+"%", "%"+query+"%")
+		}
+		if err := q.Order("id ASC").Limit(limit).Offset(offset).Scan(&users).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch users")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    users,
+			"meta": fiber.Map{
+				"limit":  limit,
+				"offset": offset,
+				"count":  len(users),
+			},
+		})
+	})
+
+	r.Put("/users/:id/role", manageRoles, func(c *fiber.Ctx) error {
+		userID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid user id")
+		}
+		var body struct {
+			Role string `json:"role"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Role == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "role required")
+		}
+
+		if err := authSvc.AssignRole(c.Locals("userID").(uint), uint(userID), body.Role); err != nil {
+			if err == gorm.ErrRecordNotFound {
+				return fiber.NewError(fiber.StatusNotFound, "user not found")
+			}
+			return roleError(err)
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "role updated",
+		})
+	})
+
+	r.Post("/users/:id/revoke-sessions", middleware.RequirePermission(authSvc, auth.PermUsersManage), func(c *fiber.Ctx) error {
+		userID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid user id")
+		}
+
+		if err := authSvc.RevokeAllSessions(uint(userID)); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to revoke sessions")
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "all sessions of the user were revoked",
+		})
+	})
+}
+
+func roleError(err error) error {
+	switch err {
+	case auth.ErrRoleNotFound:
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case auth.ErrRoleExists, auth.ErrRoleInUse, auth.ErrLastAdmin:
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case auth.ErrRoleBuiltIn, auth.ErrPermissionNotHeld:
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	case auth.ErrInvalidRoleName, auth.ErrUnknownPermission:
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return fiber.NewError(fiber.StatusInternalServerError, "failed to update roles")
+}
@@ -0,0 +1,159 @@
+package auth
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type Attempt struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// AttemptStore counts failures per key ("login:ip:1.2.3.4", ...). Failures
+// older than the window passed to RecordFailure are forgotten.
+type AttemptStore interface {
+	Get(key string) (Attempt, error)
+	RecordFailure(key string, now time.Time, window time.Duration) (Attempt, error)
+	Lock(key string, until time.Time) error
+	Reset(key string) error
+}
+
+type MemoryAttemptStore struct {
+	mu        sync.Mutex
+	attempts  map[string]*memoryAttempt
+	lastSweep time.Time
+}
+
+// memoryAttempt remembers the window of the limiter that recorded it, since
+// limiters with different windows share the store.
+type memoryAttempt struct {
+	Attempt
+	window time.Duration
+}
+
+func NewMemoryAttemptStore() *MemoryAttemptStore {
+	return &MemoryAttemptStore{attempts: make(map[string]*memoryAttempt)}
+}
+
+func (m *MemoryAttemptStore) Get(key string) (Attempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if a, ok := m.attempts[key]; ok {
+		return a.Attempt, nil
+	}
+	return Attempt{}, nil
+}
+
+func (m *MemoryAttemptStore) RecordFailure(key string, now time.Time, window time.Duration) (Attempt, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if now.Sub(m.lastSweep) > time.Minute {
+		m.sweep(now)
+	}
+
+	a, ok := m.attempts[key]
+	if !ok {
+		a = &memoryAttempt{}
+		m.attempts[key] = a
+	}
+	if now.Sub(a.LastFailure) > window {
+		a.Failures = 0
+	}
+	a.Failures++
+	a.LastFailure = now
+	a.window = window
+	return a.Attempt, nil
+}
+
+func (m *MemoryAttemptStore) sweep(now time.Time) {
+	for k, a := range m.attempts {
+		if now.Sub(a.LastFailure) > a.window && now.After(a.LockedUntil) {
+			delete(m.attempts, k)
+		}
+	}
+	m.lastSweep = now
+}
+
+func (m *MemoryAttemptStore) Lock(key string, until time.Time) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if a, ok := m.attempts[key]; ok {
+		a.LockedUntil = until
+	}
+	return nil
+}
+
+func (m *MemoryAttemptStore) Reset(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.attempts, key)
+	return nil
+}
+
+// LoginAttempt backs PostgresAttemptStore so counters are shared between
+// instances and survive restarts.
+type LoginAttempt struct {
+	Key         string `gorm:"primaryKey;size:255"`
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
+}
+
+// attemptRetention is how long idle rows are kept in login_attempts. It is
+// longer than any limiter window, so only counters that no longer matter
+// are removed.
+const attemptRetention = 24 * time.Hour
+
+type PostgresAttemptStore struct {
+	DB *gorm.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+func (p *PostgresAttemptStore) Get(key string) (Attempt, error) {
+	var row LoginAttempt
+	if err := p.DB.Where("key = ?", key).Limit(1).Find(&row).Error; err != nil {
+		return Attempt{}, err
+	}
+	return Attempt{Failures: row.Failures, LastFailure: row.LastFailure, LockedUntil: row.LockedUntil}, nil
+}
+
+func (p *PostgresAttemptStore) RecordFailure(key string, now time.Time, window time.Duration) (Attempt, error) {
+	p.mu.Lock()
+	if now.Sub(p.lastSweep) > time.Minute {
+		p.lastSweep = now
+		p.DB.Where("last_failure < ? AND locked_until < ?", now.Add(-attemptRetention), now).Delete(&LoginAttempt{})
+	}
+	p.mu.Unlock()
+
+	row := LoginAttempt{Key: key, Failures: 1, LastFailure: now}
+	err := p.DB.Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "key"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"failures":     gorm.Expr("CASE WHEN login_attempts.last_failure < ? THEN 1 ELSE login_attempts.failures + 1 END", now.Add(-window)),
+				"last_failure": now,
+			}),
+		},
+		clause.Returning{},
+	).Create(&row).Error
+	if err != nil {
+		return Attempt{}, err
+	}
+	return Attempt{Failures: row.Failures, LastFailure: row.LastFailure, LockedUntil: row.LockedUntil}, nil
+}
+
+func (p *PostgresAttemptStore) Lock(key string, until time.Time) error {
+	return p.DB.Model(&LoginAttempt{}).Where("key = ?", key).Update("locked_until", until).Error
+}
+
+func (p *PostgresAttemptStore) Reset(key string) error {
+	return p.DB.Where("key = ?", key).Delete(&LoginAttempt{}).Error
+}
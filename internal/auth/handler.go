@@ -1,26 +1,50 @@
 package auth
 
 import (
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 )
 
 func RegisterRoutes(app *fiber.App, db *gorm.DB, svc *AuthService) {
+	app.Get("/.well-known/jwks.json", func(c *fiber.Ctx) error {
+		c.Set(fiber.HeaderCacheControl, "public, max-age=300")
+		return c.JSON(svc.Keys.JWKS())
+	})
+
 	r := app.Group("/auth")
 
 	r.Post("/register", func(c *fiber.Ctx) error {
+		ip := c.IP()
+		if wait := lockoutFor(limitCheck{svc.Guard.RegisterIP, ip}); wait > 0 {
+			return tooManyAttempts(c, wait)
+		}
+
 		var req RegisterReq
 		if err := c.BodyParser(&req); err != nil {
 			return fiber.NewError(fiber.StatusBadRequest, "invalid body")
 		}
+
+		// Every registration counts, successful or not, to slow down scripted
+		// sign-ups. The one that reaches the limit is refused as well.
+		if wait := recordFailure(limitCheck{svc.Guard.RegisterIP, ip}); wait > 0 {
+			return tooManyAttempts(c, wait)
+		}
+
 		u, err := svc.Register(req)
 		if err != nil {
 			return fiber.NewError(fiber.StatusBadRequest, err.Error())
 		}
 		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-			"id":       u.ID,
-			"username": u.Username,
-			"email":    u.Email,
+			"id":             u.ID,
+			"username":       u.Username,
+			"email":          u.Email,
+			"email_verified": false,
 		})
 	})
 
@@ -30,11 +54,79 @@ func RegisterRoutes(app *fiber.App, db *gorm.DB, svc *AuthService) {
 			return fiber.NewError(fiber.StatusBadRequest, "invalid body")
 		}
 
-		tok, err := svc.Login(req)
+		ip, account := c.IP(), AccountKey(req.Email)
+		checks := []limitCheck{{svc.Guard.LoginAccount, account}, {svc.Guard.LoginIP, ip}}
+		if wait := lockoutFor(checks...); wait > 0 {
+			return tooManyAttempts(c, wait)
+		}
+
+		res, err := svc.Login(req, clientInfo(c))
+		if err != nil {
+			if err == ErrInvalidCredentials {
+				if wait := recordFailure(checks...); wait > 0 {
+					return tooManyAttempts(c, wait)
+				}
+			}
+			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+		}
+
+		// With two-factor enabled the account counter is only reset once
+		// the code is accepted, see /login/mfa.
+		if res.MFAToken != "" {
+			return c.JSON(fiber.Map{
+				"success":      true,
+				"mfa_required": true,
+				"mfa_token":    res.MFAToken,
+			})
+		}
+
+		if err := svc.Guard.LoginAccount.Reset(account); err != nil {
+			log.Printf("⚠️ rate limit store error: %v", err)
+		}
+
+		tok := res.Tokens
+		return c.JSON(fiber.Map{
+			"success":        true,
+			"access_token":   tok.AccessToken,
+			"refresh_token":  tok.RefreshToken,
+			"token_type":     "Bearer",
+			"expires_in_sec": 86400,
+		})
+	})
+
+	r.Post("/login/mfa", func(c *fiber.Ctx) error {
+		var body struct {
+			MFAToken string `json:"mfa_token"`
+			Code     string `json:"code"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.MFAToken == "" || body.Code == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "mfa_token and code required")
+		}
+
+		u, err := svc.MFAChallengeUser(body.MFAToken)
+		if err != nil {
+			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+		}
+		account := AccountKey(u.Email)
+		checks := []limitCheck{{svc.Guard.LoginAccount, account}, {svc.Guard.LoginIP, c.IP()}}
+		if wait := lockoutFor(checks...); wait > 0 {
+			return tooManyAttempts(c, wait)
+		}
+
+		tok, err := svc.CompleteMFALogin(body.MFAToken, body.Code, clientInfo(c))
 		if err != nil {
+			if err == ErrInvalidMFACode {
+				if wait := recordFailure(checks...); wait > 0 {
+					return tooManyAttempts(c, wait)
+				}
+			}
 			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
 		}
 
+		if err := svc.Guard.LoginAccount.Reset(account); err != nil {
+			log.Printf("⚠️ rate limit store error: %v", err)
+		}
+
 		return c.JSON(fiber.Map{
 			"success":        true,
 			"access_token":   tok.AccessToken,
@@ -52,7 +144,7 @@ func RegisterRoutes(app *fiber.App, db *gorm.DB, svc *AuthService) {
 			return fiber.NewError(fiber.StatusBadRequest, "invalid body")
 		}
 
-		tok, err := svc.RefreshAccess(body.RefreshToken)
+		tok, err := svc.RefreshAccess(body.RefreshToken, clientInfo(c))
 		if err != nil {
 			return fiber.NewError(fiber.StatusUnauthorized, err.Error())
 		}
@@ -66,6 +158,56 @@ func RegisterRoutes(app *fiber.App, db *gorm.DB, svc *AuthService) {
 		})
 	})
 
+	r.Post("/password/forgot", func(c *fiber.Ctx) error {
+		ip := c.IP()
+		if wait := lockoutFor(limitCheck{svc.Guard.PasswordResetIP, ip}); wait > 0 {
+			return tooManyAttempts(c, wait)
+		}
+
+		var body struct {
+			Email string `json:"email"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Email == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "email required")
+		}
+
+		// Every request sends an email, so they all count.
+		if wait := recordFailure(limitCheck{svc.Guard.PasswordResetIP, ip}); wait > 0 {
+			return tooManyAttempts(c, wait)
+		}
+
+		if err := svc.RequestPasswordReset(body.Email); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to process request")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "If the email is registered, a password reset link has been sent",
+		})
+	})
+
+	r.Post("/password/reset", func(c *fiber.Ctx) error {
+		var body struct {
+			Token    string `json:"token"`
+			Password string `json:"password"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Token == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "token and password required")
+		}
+
+		if err := svc.ResetPassword(body.Token, body.Password); err != nil {
+			if err == ErrInvalidResetToken || err == ErrPasswordTooShort {
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			}
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to reset password")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Password updated, please login again",
+		})
+	})
+
 	r.Post("/logout", func(c *fiber.Ctx) error {
 		var body struct {
 			RefreshToken string `json:"refresh_token"`
@@ -74,13 +216,292 @@ func RegisterRoutes(app *fiber.App, db *gorm.DB, svc *AuthService) {
 			return fiber.NewError(fiber.StatusBadRequest, "refresh_token required")
 		}
 
-		if err := svc.DB.Where("token = ?", body.RefreshToken).Delete(&RefreshToken{}).Error; err != nil {
+		if err := svc.Logout(body.RefreshToken); err != nil {
 			return fiber.NewError(fiber.StatusInternalServerError, "failed to logout")
 		}
 
+		if h := c.Get(fiber.HeaderAuthorization); strings.HasPrefix(h, "Bearer ") {
+			if claims, err := svc.ParseClaims(strings.TrimPrefix(h, "Bearer ")); err == nil && claims.ID != "" {
+				if err := svc.RevokeAccessToken(claims); err != nil {
+					return fiber.NewError(fiber.StatusInternalServerError, "failed to logout")
+				}
+			}
+		}
+
 		return c.JSON(fiber.Map{
 			"success": true,
 			"message": "Logged out successfully",
 		})
 	})
 }
+
+// RegisterSessionRoutes mounts the session management endpoints. The auth
+// middleware is passed in because the middleware package depends on auth.
+func RegisterSessionRoutes(app *fiber.App, svc *AuthService, protected fiber.Handler) {
+	r := app.Group("/auth/sessions", protected)
+
+	r.Get("/", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		current, _ := c.Locals("sessionID").(string)
+
+		sessions, err := svc.ListSessions(userID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch sessions")
+		}
+
+		type sessionResp struct {
+			Session
+			Current bool `json:"current"`
+		}
+		resp := make([]sessionResp, 0, len(sessions))
+		for _, sess := range sessions {
+			resp = append(resp, sessionResp{Session: sess, Current: sess.FamilyID == current})
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    resp,
+		})
+	})
+
+	r.Delete("/", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+
+		if err := svc.RevokeAllSessions(userID); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to revoke sessions")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Logged out from all devices",
+		})
+	})
+
+	r.Delete("/others", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		current, _ := c.Locals("sessionID").(string)
+		if current == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "current session unknown, please login again")
+		}
+
+		if err := svc.RevokeOtherSessions(userID, current); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to revoke sessions")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Logged out from all other sessions",
+		})
+	})
+
+	r.Delete("/:id", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		sessionID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid session id")
+		}
+
+		if err := svc.RevokeSession(userID, uint(sessionID)); err != nil {
+			if err == ErrSessionNotFound {
+				return fiber.NewError(fiber.StatusNotFound, err.Error())
+			}
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to revoke session")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Session revoked",
+		})
+	})
+}
+
+// RegisterVerificationRoutes mounts the email verification endpoints.
+func RegisterVerificationRoutes(app *fiber.App, svc *AuthService, protected fiber.Handler) {
+	r := app.Group("/auth/verify-email")
+
+	r.Post("/", func(c *fiber.Ctx) error {
+		var body struct {
+			Token string `json:"token"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Token == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "token required")
+		}
+
+		if err := svc.VerifyEmail(body.Token); err != nil {
+			switch err {
+			case ErrInvalidVerificationToken:
+				return fiber.NewError(fiber.StatusBadRequest, err.Error())
+			case ErrEmailTaken:
+				return fiber.NewError(fiber.StatusConflict, err.Error())
+			}
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to verify email")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Email verified",
+		})
+	})
+
+	r.Post("/resend", protected, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+
+		if err := svc.ResendVerification(userID); err != nil {
+			switch err {
+			case ErrEmailAlreadyVerified:
+				return fiber.NewError(fiber.StatusConflict, err.Error())
+			case ErrVerificationCooldown:
+				return fiber.NewError(fiber.StatusTooManyRequests, err.Error())
+			}
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to send verification email")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Verification email sent",
+		})
+	})
+}
+
+// RegisterMFARoutes mounts two-factor enrollment and management endpoints.
+func RegisterMFARoutes(app *fiber.App, svc *AuthService, protected fiber.Handler) {
+	r := app.Group("/auth/mfa", protected)
+
+	r.Post("/totp/setup", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+
+		setup, err := svc.BeginTOTPSetup(userID)
+		if err != nil {
+			if err == ErrMFAAlreadyEnabled {
+				return fiber.NewError(fiber.StatusConflict, err.Error())
+			}
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to start two-factor setup")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    setup,
+		})
+	})
+
+	r.Post("/totp/enable", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		var body struct {
+			Code string `json:"code"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Code == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "code required")
+		}
+
+		codes, err := svc.EnableTOTP(userID, body.Code)
+		if err != nil {
+			return mfaError(err)
+		}
+
+		return c.JSON(fiber.Map{
+			"success":        true,
+			"recovery_codes": codes,
+		})
+	})
+
+	r.Post("/totp/disable", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		var body struct {
+			Password string `json:"password"`
+			Code     string `json:"code"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Password == "" || body.Code == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "password and code required")
+		}
+
+		if err := svc.DisableTOTP(userID, body.Password, body.Code); err != nil {
+			return mfaError(err)
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Two-factor authentication disabled",
+		})
+	})
+
+	r.Post("/recovery-codes", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		var body struct {
+			Code string `json:"code"`
+		}
+		if err := c.BodyParser(&body); err != nil || body.Code == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "code required")
+		}
+
+		codes, err := svc.RegenerateRecoveryCodes(userID, body.Code)
+		if err != nil {
+			return mfaError(err)
+		}
+
+		return c.JSON(fiber.Map{
+			"success":        true,
+			"recovery_codes": codes,
+		})
+	})
+}
+
+func mfaError(err error) error {
+	switch err {
+	case ErrMFAAlreadyEnabled:
+		return fiber.NewError(fiber.StatusConflict, err.Error())
+	case ErrMFANotEnabled, ErrMFASetupRequired, ErrInvalidMFACode:
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	case ErrInvalidCredentials:
+		return fiber.NewError(fiber.StatusUnauthorized, err.Error())
+	}
+	return fiber.NewError(fiber.StatusInternalServerError, "two-factor request failed")
+}
+
+type limitCheck struct {
+	limiter *Limiter
+	key     string
+}
+
+// lockoutFor returns the longest remaining lockout among the checks. Store
+// errors fail open so an outage of the store cannot block every login.
+func lockoutFor(checks ...limitCheck) time.Duration {
+	var longest time.Duration
+	for _, ch := range checks {
+		wait, err := ch.limiter.RetryAfter(ch.key)
+		if err != nil {
+			log.Printf("⚠️ rate limit store error: %v", err)
+			continue
+		}
+		if wait > longest {
+			longest = wait
+		}
+	}
+	return longest
+}
+
+func recordFailure(checks ...limitCheck) time.Duration {
+	var longest time.Duration
+	for _, ch := range checks {
+		wait, err := ch.limiter.Fail(ch.key)
+		if err != nil {
+			log.Printf("⚠️ rate limit store error: %v", err)
+			continue
+		}
+		if wait > longest {
+			longest = wait
+		}
+	}
+	return longest
+}
+
+func tooManyAttempts(c *fiber.Ctx, wait time.Duration) error {
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	return fiber.NewError(fiber.StatusTooManyRequests, "too many attempts, please try again later")
+}
+
+func clientInfo(c *fiber.Ctx) ClientInfo {
+	return ClientInfo{
+		UserAgent: c.Get(fiber.HeaderUserAgent),
+		IP:        c.IP(),
+	}
+}
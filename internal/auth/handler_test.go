@@ -0,0 +1,35 @@
+package auth
+
+import (
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+func TestRegisterRejectsShortPassword(t *testing.T) {
+	// Validation happens before the database is touched.
+	svc := &AuthService{Guard: &BruteForceGuard{RegisterIP: &Limiter{
+		Store:     NewMemoryAttemptStore(),
+		Prefix:    "register:ip:",
+		Threshold: 5,
+		Window:    time.Hour,
+	}}}
+	app := fiber.New()
+	RegisterRoutes(app, nil, svc)
+
+	req := httptest.NewRequest("POST", "/auth/register",
+		strings.NewReader(`{"username":"shorty","email":"shorty@example.com","password":"1234567"}`))
+	req.Header.Set(fiber.HeaderContentType, fiber.MIMEApplicationJSON)
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != fiber.StatusBadRequest || !strings.Contains(string(body), ErrPasswordTooShort.Error()) {
+		t.Errorf("status = %d body = %q, want 400 with %q", resp.StatusCode, body, ErrPasswordTooShort)
+	}
+}
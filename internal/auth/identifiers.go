@@ -0,0 +1,255 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"strconv"
+	"strings"
+
+	"golang.org/x/text/cases"
+	"golang.org/x/text/unicode/norm"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidUsername    = errors.New("username must be 3-30 characters, start with a letter and contain only letters, digits and single underscores")
+	ErrReservedUsername   = errors.New("username is reserved")
+	ErrConfusableUsername = errors.New("username is too similar to an existing account")
+	ErrInvalidEmail       = errors.New("invalid email address")
+	ErrEmailTaken         = errors.New("email already used")
+	ErrUsernameTaken      = errors.New("username already used")
+)
+
+// reservedUsernames are names that could be mistaken for the service itself
+// or that collide with routes. They are compared by skeleton, so "adm1n" is
+// reserved as well.
+var reservedUsernames = []string{
+	"admin", "administrator", "root", "system", "sysadmin", "superuser",
+	"support", "help", "helpdesk", "security", "abuse", "postmaster", "noreply", "no_reply",
+	"moderator", "mod", "staff", "team", "official", "verified",
+	"api", "auth", "oauth", "login", "logout", "register", "signup", "signin",
+	"settings", "account", "accounts", "me", "self", "users", "user",
+	"feed", "search", "explore", "notifications", "chats", "messages", "lists",
+	"www", "mail", "email", "null", "undefined", "anonymous", "everyone",
+	"unbound",
+}
+
+// protectedTerms may not appear anywhere in a username, to keep accounts
+// like "unbound_support" from posing as staff.
+var protectedTerms = []string{"unbound", "admin", "official"}
+
+var reservedSkeletons = func() map[string]bool {
+	m := make(map[string]bool, len(reservedUsernames))
+	for _, name := range reservedUsernames {
+		m[usernameSkeleton(name)] = true
+	}
+	return m
+}()
+
+var foldCase = cases.Fold()
+
+// canonicalize applies NFKC and Unicode case folding, so full-width letters,
+// ligatures and case variants of the same text compare equal.
+func canonicalize(s string) string {
+	return norm.NFKC.String(foldCase.String(norm.NFKC.String(strings.TrimSpace(s))))
+}
+
+// CanonicalUsername is the form usernames are unique on and looked up by.
+func CanonicalUsername(name string) string {
+	return canonicalize(name)
+}
+
+// CanonicalEmail is the form emails are unique on and looked up by.
+func CanonicalEmail(email string) string {
+	return canonicalize(email)
+}
+
+// usernameSkeleton maps characters that look alike onto one representative,
+// so "paypa1", "paypal" and "pay_pal" share a skeleton. Two accounts may not
+// have the same skeleton.
+func usernameSkeleton(name string) string {
+	s := canonicalize(name)
+	s = strings.NewReplacer("rn", "m", "vv", "w").Replace(s)
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '_':
+			continue
+		case '0':
+			r = 'o'
+		case '1', 'i':
+			r = 'l'
+		case '5':
+			r = 's'
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// normalizeUsername returns the display form of a requested username and
+// checks it against the format rules and the reserved names.
+func normalizeUsername(name string) (string, error) {
+	name = norm.NFKC.String(strings.TrimSpace(name))
+	if len(name) < 3 || len(name) > 30 {
+		return "", ErrInvalidUsername
+	}
+	for i, r := range name {
+		letter := r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z'
+		digit := r >= '0' && r <= '9'
+		switch {
+		case i == 0 && !letter:
+			return "", ErrInvalidUsername
+		case r == '_':
+			if name[i-1] == '_' || i == len(name)-1 {
+				return "", ErrInvalidUsername
+			}
+		case !letter && !digit:
+			return "", ErrInvalidUsername
+		}
+	}
+
+	skeleton := usernameSkeleton(name)
+	if reservedSkeletons[skeleton] {
+		return "", ErrReservedUsername
+	}
+	for _, term := range protectedTerms {
+		if strings.Contains(skeleton, usernameSkeleton(term)) {
+			return "", ErrReservedUsername
+		}
+	}
+	return name, nil
+}
+
+// normalizeEmail returns the display form of an email address after a basic
+// shape check; ownership is proven by the verification email.
+func normalizeEmail(email string) (string, error) {
+	email = norm.NFKC.String(strings.TrimSpace(email))
+	at := strings.LastIndex(email, "@")
+	if at <= 0 || at == len(email)-1 || strings.Count(email, "@") != 1 ||
+		strings.ContainsAny(email, " \t\r\n<>,;\"") || len(email) > 254 {
+		return "", ErrInvalidEmail
+	}
+	return email, nil
+}
+
+// checkUsernameFree reports whether the username can be given to the
+// account exceptID (0 for a new account). Deleted accounts still hold on to
+// their names until they are purged.
+func checkUsernameFree(tx *gorm.DB, name string, exceptID uint) error {
+	var count int64
+	if err := tx.Unscoped().Model(&User{}).
+		Where("username_canonical = ? AND id <> ?", CanonicalUsername(name), exceptID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrUsernameTaken
+	}
+
+	if err := tx.Unscoped().Model(&User{}).
+		Where("username_skeleton = ? AND id <> ?", usernameSkeleton(name), exceptID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrConfusableUsername
+	}
+	return nil
+}
+
+func checkEmailFree(tx *gorm.DB, email string, exceptID uint) error {
+	var count int64
+	if err := tx.Unscoped().Model(&User{}).
+		Where("email_canonical = ? AND id <> ?", CanonicalEmail(email), exceptID).
+		Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return ErrEmailTaken
+	}
+	return nil
+}
+
+// setUsername and setEmail keep the canonical columns in step with the
+// display forms.
+func (u *User) setUsername(name string) {
+	u.Username = name
+	u.UsernameCanonical = CanonicalUsername(name)
+	u.UsernameSkeleton = usernameSkeleton(name)
+}
+
+func (u *User) setEmail(email string) {
+	u.Email = email
+	u.EmailCanonical = CanonicalEmail(email)
+}
+
+// WhereUsername scopes a users query to the account with the given name.
+// An exact match wins over a canonical one for the few legacy accounts that
+// differ only in case.
+func WhereUsername(name string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("users.username = ? OR users.username_canonical = ?", name, CanonicalUsername(name)).
+			Order(clause.Expr{SQL: "users.username = ? DESC", Vars: []interface{}{name}})
+	}
+}
+
+// WhereEmail is WhereUsername for email addresses.
+func WhereEmail(email string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("users.email = ? OR users.email_canonical = ?", email, CanonicalEmail(email)).
+			Order(clause.Expr{SQL: "users.email = ? DESC", Vars: []interface{}{email}})
+	}
+}
+
+// BackfillCanonicalIdentifiers fills the canonical columns for accounts that
+// existed before them. It must run before AutoMigrate builds the unique
+// indexes. Accounts that collide with an older one, like "Alice" and
+// "alice", get a suffixed canonical form so the index can be built; they
+// keep working through exact-match lookups and are logged for review.
+func BackfillCanonicalIdentifiers(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&User{}) || m.HasColumn(&User{}, "username_canonical") {
+		return nil
+	}
+	for _, field := range []string{"UsernameCanonical", "UsernameSkeleton", "EmailCanonical"} {
+		if err := m.AddColumn(&User{}, field); err != nil {
+			return err
+		}
+	}
+
+	var users []User
+	if err := db.Unscoped().Select("id", "username", "email").Order("id ASC").Find(&users).Error; err != nil {
+		return err
+	}
+
+	seenNames := map[string]bool{}
+	seenEmails := map[string]bool{}
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, u := range users {
+			u.setUsername(u.Username)
+			u.setEmail(u.Email)
+			suffix := "~" + strconv.FormatUint(uint64(u.ID), 10)
+			if seenNames[u.UsernameCanonical] {
+				log.Printf("⚠️ username %q of user %d collides with an older account", u.Username, u.ID)
+				u.UsernameCanonical += suffix
+			}
+			if seenEmails[u.EmailCanonical] {
+				log.Printf("⚠️ email of user %d collides with an older account", u.ID)
+				u.EmailCanonical += suffix
+			}
+			seenNames[u.UsernameCanonical] = true
+			seenEmails[u.EmailCanonical] = true
+
+			if err := tx.Model(&User{}).Unscoped().Where("id = ?", u.ID).Updates(map[string]interface{}{
+				"username_canonical": u.UsernameCanonical,
+				"username_skeleton":  u.UsernameSkeleton,
+				"email_canonical":    u.EmailCanonical,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestUsernameSkeleton(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"paypal", "paypal"},
+		{"PayPal", "paypal"},
+		{"paypa1", "paypal"},
+		{"pay_pal", "paypal"},
+		{"ＰａｙＰａｌ", "paypal"},
+		{"rnodern", "modem"},
+		{"vvest", "west"},
+		{"l1i", "lll"},
+		{"s0s5", "soss"},
+		{"adm1n", "admln"},
+	}
+	for _, tt := range tests {
+		if got := usernameSkeleton(tt.name); got != tt.want {
+			t.Errorf("usernameSkeleton(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNormalizeUsername(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    string
+		wantErr error
+	}{
+		{"alice", "alice", nil},
+		{"  Alice_B  ", "Alice_B", nil},
+		{"ａｌｉｃｅ", "alice", nil},
+		{"bob", "bob", nil},
+		{"admiral", "admiral", nil},
+		{"a1_b2_c3", "a1_b2_c3", nil},
+		{"ab", "", ErrInvalidUsername},
+		{strings.Repeat("a", 31), "", ErrInvalidUsername},
+		{"1alice", "", ErrInvalidUsername},
+		{"_alice", "", ErrInvalidUsername},
+		{"alice__b", "", ErrInvalidUsername},
+		{"alice_", "", ErrInvalidUsername},
+		{"alice-b", "", ErrInvalidUsername},
+		{"élise", "", ErrInvalidUsername},
+		{"admin", "", ErrReservedUsername},
+		{"Adm1n", "", ErrReservedUsername},
+		{"Root", "", ErrReservedUsername},
+		{"rnod", "", ErrReservedUsername},
+		{"no_reply", "", ErrReservedUsername},
+		{"unbound_fan", "", ErrReservedUsername},
+		{"offic1al_news", "", ErrReservedUsername},
+	}
+	for _, tt := range tests {
+		got, err := normalizeUsername(tt.name)
+		if err != tt.wantErr || got != tt.want {
+			t.Errorf("normalizeUsername(%q) = %q, %v; want %q, %v", tt.name, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestCanonicalEmail(t *testing.T) {
+	tests := []struct {
+		email string
+		want  string
+	}{
+		{"alice@example.com", "alice@example.com"},
+		{"  Alice@Example.COM ", "alice@example.com"},
+		{"ＡＬＩＣＥ@example.com", "alice@example.com"},
+	}
+	for _, tt := range tests {
+		if got := CanonicalEmail(tt.email); got != tt.want {
+			t.Errorf("CanonicalEmail(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+		if got := AccountKey(tt.email); got != tt.want {
+			t.Errorf("AccountKey(%q) = %q, want %q", tt.email, got, tt.want)
+		}
+	}
+}
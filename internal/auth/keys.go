@@ -0,0 +1,198 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+type signingKey struct {
+	ID      string
+	Method  jwt.SigningMethod
+	Private crypto.Signer
+	Public  crypto.PublicKey
+}
+
+// KeySet holds the key used to sign new access tokens and every key that is
+// still accepted for verification. Rotating means adding a new private key,
+// switching JWT_ACTIVE_KID to it and keeping the old key (or just its public
+// half) around until tokens signed with it have expired.
+type KeySet struct {
+	active *signingKey
+	keys   map[string]*signingKey
+}
+
+// LoadKeySet reads every *.pem file in JWT_KEYS_DIR. The file name without
+// extension is the key id. Files may hold a PKCS#8 Ed25519/RSA private key or
+// a PKIX public key for verify-only keys. Without JWT_KEYS_DIR an ephemeral
+// Ed25519 key is generated, which is only suitable for local development.
+func LoadKeySet() (*KeySet, error) {
+	dir := os.Getenv("JWT_KEYS_DIR")
+	if dir == "" {
+		log.Println("⚠️ JWT_KEYS_DIR not set, using an ephemeral signing key (tokens will not survive a restart)")
+		return ephemeralKeySet()
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	ks := &KeySet{keys: make(map[string]*signingKey)}
+	var signers []string
+	for _, f := range files {
+		kid := strings.TrimSuffix(filepath.Base(f), ".pem")
+		raw, err := os.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		k, err := parseKey(kid, raw)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", kid, err)
+		}
+		ks.keys[kid] = k
+		if k.Private != nil {
+			signers = append(signers, kid)
+		}
+	}
+
+	activeKID := os.Getenv("JWT_ACTIVE_KID")
+	if activeKID == "" && len(signers) > 0 {
+		sort.Strings(signers)
+		activeKID = signers[len(signers)-1]
+	}
+	active, ok := ks.keys[activeKID]
+	if !ok || active.Private == nil {
+		return nil, fmt.Errorf("no private signing key %q found in %s", activeKID, dir)
+	}
+	ks.active = active
+	return ks, nil
+}
+
+func ephemeralKeySet() (*KeySet, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	kid, err := randomHex(8)
+	if err != nil {
+		return nil, err
+	}
+	k := &signingKey{ID: kid, Method: jwt.SigningMethodEdDSA, Private: priv, Public: pub}
+	return &KeySet{active: k, keys: map[string]*signingKey{kid: k}}, nil
+}
+
+func parseKey(kid string, raw []byte) (*signingKey, error) {
+	block, _ := pem.Decode(raw)
+	if block == nil {
+		return nil, errors.New("no PEM data")
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch priv := key.(type) {
+		case ed25519.PrivateKey:
+			return &signingKey{ID: kid, Method: jwt.SigningMethodEdDSA, Private: priv, Public: priv.Public()}, nil
+		case *rsa.PrivateKey:
+			return &signingKey{ID: kid, Method: jwt.SigningMethodRS256, Private: priv, Public: priv.Public()}, nil
+		}
+	case "RSA PRIVATE KEY":
+		priv, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return &signingKey{ID: kid, Method: jwt.SigningMethodRS256, Private: priv, Public: priv.Public()}, nil
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		switch pub := key.(type) {
+		case ed25519.PublicKey:
+			return &signingKey{ID: kid, Method: jwt.SigningMethodEdDSA, Public: pub}, nil
+		case *rsa.PublicKey:
+			return &signingKey{ID: kid, Method: jwt.SigningMethodRS256, Public: pub}, nil
+		}
+	}
+	return nil, fmt.Errorf("unsupported key type %q", block.Type)
+}
+
+func (ks *KeySet) Sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(ks.active.Method, claims)
+	token.Header["kid"] = ks.active.ID
+	return token.SignedString(ks.active.Private)
+}
+
+func (ks *KeySet) Parse(tokenStr string, claims jwt.Claims) (*jwt.Token, error) {
+	return jwt.ParseWithClaims(tokenStr, claims, ks.keyFunc,
+		jwt.WithValidMethods([]string{jwt.SigningMethodEdDSA.Alg(), jwt.SigningMethodRS256.Alg()}),
+		jwt.WithIssuer("unbound"),
+		jwt.WithExpirationRequired(),
+	)
+}
+
+func (ks *KeySet) keyFunc(t *jwt.Token) (interface{}, error) {
+	kid, _ := t.Header["kid"].(string)
+	k, ok := ks.keys[kid]
+	if !ok {
+		return nil, errors.New("unknown signing key")
+	}
+	if t.Method.Alg() != k.Method.Alg() {
+		return nil, errors.New("unexpected signing method")
+	}
+	return k.Public, nil
+}
+
+// JWKS returns the verification keys in RFC 7517 JSON Web Key Set form.
+func (ks *KeySet) JWKS() map[string]interface{} {
+	kids := make([]string, 0, len(ks.keys))
+	for kid := range ks.keys {
+		kids = append(kids, kid)
+	}
+	sort.Strings(kids)
+
+	b64 := base64.RawURLEncoding
+	keys := make([]map[string]string, 0, len(kids))
+	for _, kid := range kids {
+		k := ks.keys[kid]
+		switch pub := k.Public.(type) {
+		case ed25519.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "OKP",
+				"crv": "Ed25519",
+				"x":   b64.EncodeToString(pub),
+				"kid": kid,
+				"alg": k.Method.Alg(),
+				"use": "sig",
+			})
+		case *rsa.PublicKey:
+			keys = append(keys, map[string]string{
+				"kty": "RSA",
+				"n":   b64.EncodeToString(pub.N.Bytes()),
+				"e":   b64.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+				"kid": kid,
+				"alg": k.Method.Alg(),
+				"use": "sig",
+			})
+		}
+	}
+	return map[string]interface{}{"keys": keys}
+}
@@ -0,0 +1,277 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const (
+	mfaChallengeTTL      = 5 * time.Minute
+	mfaMaxAttempts       = 5
+	recoveryCodeCount    = 10
+	recoveryCodeHalfSize = 5
+)
+
+var (
+	ErrMFAAlreadyEnabled   = errors.New("two-factor authentication is already enabled")
+	ErrMFANotEnabled       = errors.New("two-factor authentication is not enabled")
+	ErrMFASetupRequired    = errors.New("start two-factor setup first")
+	ErrInvalidMFACode      = errors.New("invalid authentication code")
+	ErrInvalidMFAChallenge = errors.New("invalid or expired login challenge, please login again")
+)
+
+type TOTPSetup struct {
+	Secret     string `json:"secret"`
+	OTPAuthURI string `json:"otpauth_uri"`
+}
+
+// BeginTOTPSetup generates a fresh secret for the user. It only takes effect
+// once EnableTOTP confirms the user can produce codes for it.
+func (s *AuthService) BeginTOTPSetup(userID uint) (*TOTPSetup, error) {
+	var u User
+	if err := s.DB.First(&u, userID).Error; err != nil {
+		return nil, err
+	}
+	if u.TOTPEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return nil, err
+	}
+	if err := s.DB.Model(&u).Updates(map[string]interface{}{
+		"totp_secret":    secret,
+		"totp_last_step": 0,
+	}).Error; err != nil {
+		return nil, err
+	}
+
+	return &TOTPSetup{Secret: secret, OTPAuthURI: totpURI(secret, u.Username)}, nil
+}
+
+// EnableTOTP confirms the pending secret and returns the recovery codes,
+// which are only ever shown this once.
+func (s *AuthService) EnableTOTP(userID uint, code string) ([]string, error) {
+	var u User
+	if err := s.DB.First(&u, userID).Error; err != nil {
+		return nil, err
+	}
+	if u.TOTPEnabledAt != nil {
+		return nil, ErrMFAAlreadyEnabled
+	}
+	if u.TOTPSecret == "" {
+		return nil, ErrMFASetupRequired
+	}
+	if err := s.consumeTOTP(&u, code); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&u).Update("totp_enabled_at", time.Now()).Error; err != nil {
+			return err
+		}
+		var err error
+		codes, err = s.replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+func (s *AuthService) DisableTOTP(userID uint, password, code string) error {
+	var u User
+	if err := s.DB.First(&u, userID).Error; err != nil {
+		return err
+	}
+	if u.TOTPEnabledAt == nil {
+		return ErrMFANotEnabled
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)); err != nil {
+		return ErrInvalidCredentials
+	}
+	if err := s.verifySecondFactor(&u, code); err != nil {
+		return err
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&u).Updates(map[string]interface{}{
+			"totp_secret":     "",
+			"totp_enabled_at": nil,
+			"totp_last_step":  0,
+		}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error
+	})
+}
+
+func (s *AuthService) RegenerateRecoveryCodes(userID uint, code string) ([]string, error) {
+	var u User
+	if err := s.DB.First(&u, userID).Error; err != nil {
+		return nil, err
+	}
+	if u.TOTPEnabledAt == nil {
+		return nil, ErrMFANotEnabled
+	}
+	if err := s.consumeTOTP(&u, code); err != nil {
+		return nil, err
+	}
+
+	var codes []string
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		var err error
+		codes, err = s.replaceRecoveryCodes(tx, userID)
+		return err
+	})
+	return codes, err
+}
+
+// MFAChallengeUser returns the user a pending login challenge belongs to, so
+// failed codes can be counted against the same account as failed passwords.
+func (s *AuthService) MFAChallengeUser(mfaToken string) (*User, error) {
+	var u User
+	err := s.DB.Joins("JOIN mfa_challenges ch ON ch.user_id = users.id").
+		Where("ch.token_hash = ?", s.HashToken(mfaToken)).
+		First(&u).Error
+	if err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+	return &u, nil
+}
+
+// CompleteMFALogin finishes a login started by Login when two-factor
+// authentication is enabled. code may be a TOTP code or a recovery code.
+// Every call uses up one of the challenge's attempts before the code is
+// checked, so concurrent guesses cannot get past mfaMaxAttempts.
+func (s *AuthService) CompleteMFALogin(mfaToken, code string, client ClientInfo) (*TokenResp, error) {
+	hash := s.HashToken(mfaToken)
+	res := s.DB.Model(&MFAChallenge{}).
+		Where("token_hash = ? AND attempts < ? AND expires_at > ?", hash, mfaMaxAttempts, time.Now()).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		s.DB.Where("token_hash = ?", hash).Delete(&MFAChallenge{})
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	var ch MFAChallenge
+	if err := s.DB.Where("token_hash = ?", hash).First(&ch).Error; err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+	var u User
+	if err := s.DB.First(&u, ch.UserID).Error; err != nil {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	if err := s.verifySecondFactor(&u, code); err != nil {
+		return nil, err
+	}
+
+	res = s.DB.Delete(&MFAChallenge{}, ch.ID)
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		return nil, ErrInvalidMFAChallenge
+	}
+
+	if client.DeviceName == "" {
+		client.DeviceName = ch.DeviceName
+	}
+	if err := s.reactivate(u.ID); err != nil {
+		return nil, err
+	}
+	return s.StartSession(u.ID, client)
+}
+
+func (s *AuthService) createMFAChallenge(userID uint, deviceName string) (string, error) {
+	token, err := randomHex(32)
+	if err != nil {
+		return "", err
+	}
+	ch := MFAChallenge{
+		UserID:     userID,
+		TokenHash:  s.HashToken(token),
+		DeviceName: deviceName,
+		ExpiresAt:  time.Now().Add(mfaChallengeTTL),
+	}
+	if err := s.DB.Create(&ch).Error; err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *AuthService) verifySecondFactor(u *User, code string) error {
+	code = strings.TrimSpace(code)
+	if len(code) == totpDigits {
+		return s.consumeTOTP(u, code)
+	}
+	return s.consumeRecoveryCode(u.ID, code)
+}
+
+// consumeTOTP accepts each time step at most once so an observed code cannot
+// be replayed within its validity window.
+func (s *AuthService) consumeTOTP(u *User, code string) error {
+	step, ok := verifyTOTP(u.TOTPSecret, code, time.Now())
+	if !ok || step <= u.TOTPLastStep {
+		return ErrInvalidMFACode
+	}
+	res := s.DB.Model(&User{}).
+		Where("id = ? AND totp_last_step < ?", u.ID, step).
+		Update("totp_last_step", step)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+	u.TOTPLastStep = step
+	return nil
+}
+
+func (s *AuthService) consumeRecoveryCode(userID uint, code string) error {
+	res := s.DB.Model(&RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userID, s.HashToken(normalizeRecoveryCode(code))).
+		Update("used_at", time.Now())
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (s *AuthService) replaceRecoveryCodes(tx *gorm.DB, userID uint) ([]string, error) {
+	if err := tx.Where("user_id = ?", userID).Delete(&RecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+
+	codes := make([]string, 0, recoveryCodeCount)
+	rows := make([]RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		raw, err := randomHex(recoveryCodeHalfSize)
+		if err != nil {
+			return nil, err
+		}
+		code := raw[:recoveryCodeHalfSize] + "-" + raw[recoveryCodeHalfSize:]
+		codes = append(codes, code)
+		rows = append(rows, RecoveryCode{UserID: userID, CodeHash: s.HashToken(normalizeRecoveryCode(code))})
+	}
+	if err := tx.Create(&rows).Error; err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
@@ -0,0 +1,23 @@
+package auth
+
+import "time"
+
+type RecoveryCode struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index;not null"`
+	CodeHash  string `gorm:"uniqueIndex;size:64;not null"`
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// MFAChallenge is the half-finished login handed out after the password
+// check when the account has two-factor authentication enabled.
+type MFAChallenge struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"index;not null"`
+	TokenHash  string `gorm:"uniqueIndex;size:64;not null"`
+	DeviceName string
+	Attempts   int
+	ExpiresAt  time.Time
+	CreatedAt  time.Time
+}
@@ -1,10 +1,26 @@
 package auth
 
-import "gorm.io/gorm"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type User struct {
 	gorm.Model
-	Username string `gorm:"uniqueIndex;not null"`
-	Email    string `gorm:"uniqueIndex;not null"`
-	Password string `gorm:"not null"`
+	Username            string `gorm:"uniqueIndex;not null"`
+	UsernameCanonical   string `gorm:"uniqueIndex"`
+	UsernameSkeleton    string `gorm:"index"`
+	Email               string `gorm:"uniqueIndex;not null"`
+	EmailCanonical      string `gorm:"uniqueIndex"`
+	Password            string `gorm:"not null"`
+	Role                string `gorm:"size:64;not null;default:user;index"`
+	EmailVerifiedAt     *time.Time
+	PendingEmail        *string
+	UsernameChangedAt   *time.Time
+	DeactivatedAt       *time.Time `gorm:"index"`
+	DeletionScheduledAt *time.Time `gorm:"index"`
+	TOTPSecret          string     `gorm:"column:totp_secret"`
+	TOTPEnabledAt       *time.Time `gorm:"column:totp_enabled_at"`
+	TOTPLastStep        int64      `gorm:"column:totp_last_step;default:0"`
 }
@@ -0,0 +1,292 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"log"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const oidcAuthRequestTTL = 10 * time.Minute
+
+var (
+	ErrUnknownOIDCProvider = errors.New("unknown identity provider")
+	ErrInvalidOIDCState    = errors.New("invalid or expired login state, please try again")
+	ErrIdentityLinked      = errors.New("this identity is already linked to another account")
+	ErrOIDCEmailTaken      = errors.New("an account with this email already exists, login and link the provider from your account")
+	ErrIdentityNotFound    = errors.New("identity not found")
+	ErrRegistrationLimited = errors.New("too many accounts created from this network, please try again later")
+)
+
+// BeginOIDC starts an authorization code flow with PKCE and returns the URL
+// to send the browser to, plus a browser key the caller has to keep in a
+// cookie: CompleteOIDC only accepts the callback together with that key, so
+// a callback URL planted by someone else cannot log the victim in. With
+// linkUserID set the callback links the identity to that user instead of
+// logging in.
+func (s *AuthService) BeginOIDC(provider string, linkUserID *uint) (authURL, browserKey string, err error) {
+	p, ok := s.OIDC[provider]
+	if !ok {
+		return "", "", ErrUnknownOIDCProvider
+	}
+
+	state, err := randomHex(32)
+	if err != nil {
+		return "", "", err
+	}
+	browserKey, err = randomHex(32)
+	if err != nil {
+		return "", "", err
+	}
+	nonce, err := randomHex(16)
+	if err != nil {
+		return "", "", err
+	}
+	verifier, err := randomHex(32)
+	if err != nil {
+		return "", "", err
+	}
+	challenge := sha256.Sum256([]byte(verifier))
+
+	authURL, err = p.AuthCodeURL(state, nonce, base64.RawURLEncoding.EncodeToString(challenge[:]))
+	if err != nil {
+		return "", "", err
+	}
+
+	req := OIDCAuthRequest{
+		StateHash:    s.HashToken(state),
+		BrowserHash:  s.HashToken(browserKey),
+		Provider:     provider,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		LinkUserID:   linkUserID,
+		ExpiresAt:    time.Now().Add(oidcAuthRequestTTL),
+	}
+	if err := s.DB.Create(&req).Error; err != nil {
+		return "", "", err
+	}
+	return authURL, browserKey, nil
+}
+
+// CompleteOIDC handles the provider callback. browserKey is the key
+// BeginOIDC handed out for this flow. In link mode it returns linked=true
+// and no login result.
+func (s *AuthService) CompleteOIDC(provider, code, state, browserKey string, client ClientInfo) (res *LoginResult, linked bool, err error) {
+	p, ok := s.OIDC[provider]
+	if !ok {
+		return nil, false, ErrUnknownOIDCProvider
+	}
+	if browserKey == "" {
+		return nil, false, ErrInvalidOIDCState
+	}
+
+	var req OIDCAuthRequest
+	if err := s.DB.Where("state_hash = ? AND provider = ? AND browser_hash = ?",
+		s.HashToken(state), provider, s.HashToken(browserKey)).First(&req).Error; err != nil {
+		return nil, false, ErrInvalidOIDCState
+	}
+	if del := s.DB.Delete(&OIDCAuthRequest{}, req.ID); del.Error != nil || del.RowsAffected == 0 {
+		return nil, false, ErrInvalidOIDCState
+	}
+	if time.Now().After(req.ExpiresAt) {
+		return nil, false, ErrInvalidOIDCState
+	}
+
+	claims, err := p.Exchange(code, req.CodeVerifier, req.Nonce)
+	if err != nil {
+		return nil, false, err
+	}
+
+	var ident ExternalIdentity
+	err = s.DB.Where("provider = ? AND subject = ?", provider, claims.Subject).First(&ident).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, false, err
+	}
+	found := err == nil
+
+	if req.LinkUserID != nil {
+		if found {
+			if ident.UserID != *req.LinkUserID {
+				return nil, false, ErrIdentityLinked
+			}
+			return nil, true, nil
+		}
+		return nil, true, s.linkIdentity(*req.LinkUserID, provider, claims)
+	}
+
+	var u User
+	if found {
+		if err := s.DB.First(&u, ident.UserID).Error; err != nil {
+			return nil, false, err
+		}
+	} else {
+		user, err := s.userForNewIdentity(provider, claims, client.IP)
+		if err != nil {
+			return nil, false, err
+		}
+		u = *user
+	}
+
+	res, err = s.finishLogin(&u, "", client)
+	return res, false, err
+}
+
+// userForNewIdentity links the identity to the account with the same email
+// when both sides have verified it, and otherwise creates a new account.
+// New accounts count against the same per-IP limit as password sign-ups.
+func (s *AuthService) userForNewIdentity(provider string, claims *idTokenClaims, ip string) (*User, error) {
+	if claims.Email != "" {
+		var existing User
+		err := s.DB.Scopes(WhereEmail(claims.Email)).First(&existing).Error
+		if err == nil {
+			if !claims.emailVerified() || existing.EmailVerifiedAt == nil {
+				return nil, ErrOIDCEmailTaken
+			}
+			return &existing, s.linkIdentity(existing.ID, provider, claims)
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, err
+		}
+	}
+
+	if wait, err := s.Guard.RegisterIP.RetryAfter(ip); err != nil {
+		log.Printf("⚠️ rate limit store error: %v", err)
+	} else if wait > 0 {
+		return nil, ErrRegistrationLimited
+	}
+	if wait, err := s.Guard.RegisterIP.Fail(ip); err != nil {
+		log.Printf("⚠️ rate limit store error: %v", err)
+	} else if wait > 0 {
+		return nil, ErrRegistrationLimited
+	}
+
+	// The account gets an unusable random password; the user can set a real
+	// one through the password reset flow.
+	pw, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(pw), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+
+	username, err := s.availableUsername(claims.PreferredUsername, claims.Email)
+	if err != nil {
+		return nil, err
+	}
+
+	email := claims.Email
+	if email == "" {
+		email = provider + "-" + claims.Subject + "@users.noreply.unbound"
+	}
+	u := &User{Password: string(hash)}
+	u.setUsername(username)
+	u.setEmail(email)
+	if claims.emailVerified() && claims.Email != "" {
+		now := time.Now()
+		u.EmailVerifiedAt = &now
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(u).Error; err != nil {
+			return err
+		}
+		return tx.Create(&ExternalIdentity{
+			UserID:   u.ID,
+			Provider: provider,
+			Subject:  claims.Subject,
+			Email:    claims.Email,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func (s *AuthService) linkIdentity(userID uint, provider string, claims *idTokenClaims) error {
+	return s.DB.Create(&ExternalIdentity{
+		UserID:   userID,
+		Provider: provider,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}).Error
+}
+
+func (s *AuthService) availableUsername(candidates ...string) (string, error) {
+	base := ""
+	for _, c := range candidates {
+		if i := strings.Index(c, "@"); i >= 0 {
+			c = c[:i]
+		}
+		if base = sanitizeUsername(c); base != "" {
+			break
+		}
+	}
+	base = strings.Trim(base, "_")
+	if len(base) < 3 || base[0] < 'a' || base[0] > 'z' {
+		base = "user" + base
+	}
+	if _, err := normalizeUsername(base); err != nil {
+		base = "user"
+	}
+
+	// The bare "user" is reserved, so that base always gets a suffix.
+	name := base
+	for i := 0; i < 5; i++ {
+		if _, err := normalizeUsername(name); err == nil {
+			err = checkUsernameFree(s.DB, name, 0)
+			if err == nil {
+				return name, nil
+			}
+			if err != ErrUsernameTaken && err != ErrConfusableUsername {
+				return "", err
+			}
+		}
+		suffix, err := randomHex(2)
+		if err != nil {
+			return "", err
+		}
+		name = base + "_" + suffix
+	}
+	return "", errors.New("could not pick a username, please try again")
+}
+
+func sanitizeUsername(s string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if r == '_' && strings.HasSuffix(b.String(), "_") {
+			continue
+		}
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') || r == '_' {
+			b.WriteRune(r)
+		}
+		if b.Len() >= 24 {
+			break
+		}
+	}
+	return b.String()
+}
+
+func (s *AuthService) ListIdentities(userID uint) ([]ExternalIdentity, error) {
+	var idents []ExternalIdentity
+	err := s.DB.Where("user_id = ?", userID).Order("created_at ASC").Find(&idents).Error
+	return idents, err
+}
+
+func (s *AuthService) UnlinkIdentity(userID, identityID uint) error {
+	res := s.DB.Where("id = ? AND user_id = ?", identityID, userID).Delete(&ExternalIdentity{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrIdentityNotFound
+	}
+	return nil
+}
@@ -0,0 +1,165 @@
+package auth
+
+import (
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// oidcBrowserCookie carries the browser key of a pending OIDC flow from the
+// start of the flow to the callback.
+const oidcBrowserCookie = "oidc_browser"
+
+// RegisterOIDCRoutes mounts social login and identity linking endpoints.
+func RegisterOIDCRoutes(app *fiber.App, svc *AuthService, protected fiber.Handler) {
+	r := app.Group("/auth/oidc")
+
+	r.Get("/providers", func(c *fiber.Ctx) error {
+		names := make([]string, 0, len(svc.OIDC))
+		for name := range svc.OIDC {
+			names = append(names, name)
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    names,
+		})
+	})
+
+	r.Get("/:provider/login", func(c *fiber.Ctx) error {
+		authURL, browserKey, err := svc.BeginOIDC(c.Params("provider"), nil)
+		if err != nil {
+			return oidcError(err)
+		}
+		setOIDCBrowserCookie(c, browserKey)
+		return c.Redirect(authURL, fiber.StatusFound)
+	})
+
+	r.Post("/:provider/link", protected, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+
+		authURL, browserKey, err := svc.BeginOIDC(c.Params("provider"), &userID)
+		if err != nil {
+			return oidcError(err)
+		}
+		setOIDCBrowserCookie(c, browserKey)
+
+		return c.JSON(fiber.Map{
+			"success":           true,
+			"authorization_url": authURL,
+		})
+	})
+
+	r.Get("/:provider/callback", func(c *fiber.Ctx) error {
+		if e := c.Query("error"); e != "" {
+			return fiber.NewError(fiber.StatusUnauthorized, "provider returned error: "+e)
+		}
+		code, state := c.Query("code"), c.Query("state")
+		if code == "" || state == "" {
+			return fiber.NewError(fiber.StatusBadRequest, "code and state required")
+		}
+
+		browserKey := c.Cookies(oidcBrowserCookie)
+		// The flow is single-use, so the key is dropped whatever the outcome.
+		c.Cookie(&fiber.Cookie{
+			Name:     oidcBrowserCookie,
+			Path:     "/auth/oidc",
+			Expires:  time.Unix(0, 0),
+			HTTPOnly: true,
+			SameSite: fiber.CookieSameSiteLaxMode,
+		})
+
+		res, linked, err := svc.CompleteOIDC(c.Params("provider"), code, state, browserKey, clientInfo(c))
+		if err == ErrRegistrationLimited {
+			return tooManyAttempts(c, lockoutFor(limitCheck{svc.Guard.RegisterIP, c.IP()}))
+		}
+		if err != nil {
+			return oidcError(err)
+		}
+
+		if linked {
+			return c.JSON(fiber.Map{
+				"success": true,
+				"message": "Identity linked",
+			})
+		}
+
+		if res.MFAToken != "" {
+			return c.JSON(fiber.Map{
+				"success":      true,
+				"mfa_required": true,
+				"mfa_token":    res.MFAToken,
+			})
+		}
+
+		return c.JSON(fiber.Map{
+			"success":        true,
+			"access_token":   res.Tokens.AccessToken,
+			"refresh_token":  res.Tokens.RefreshToken,
+			"token_type":     "Bearer",
+			"expires_in_sec": 86400,
+		})
+	})
+
+	ids := app.Group("/auth/identities", protected)
+
+	ids.Get("/", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+
+		idents, err := svc.ListIdentities(userID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch identities")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    idents,
+		})
+	})
+
+	ids.Delete("/:id", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		identityID, err := strconv.ParseUint(c.Params("id"), 10, 64)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid identity id")
+		}
+
+		if err := svc.UnlinkIdentity(userID, uint(identityID)); err != nil {
+			if err == ErrIdentityNotFound {
+				return fiber.NewError(fiber.StatusNotFound, err.Error())
+			}
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to unlink identity")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Identity unlinked",
+		})
+	})
+}
+
+// setOIDCBrowserCookie stores the browser key for the callback. SameSite=Lax
+// still sends it on the top-level redirect back from the provider.
+func setOIDCBrowserCookie(c *fiber.Ctx, browserKey string) {
+	c.Cookie(&fiber.Cookie{
+		Name:     oidcBrowserCookie,
+		Value:    browserKey,
+		Path:     "/auth/oidc",
+		MaxAge:   int(oidcAuthRequestTTL.Seconds()),
+		Secure:   c.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+}
+
+func oidcError(err error) error {
+	switch err {
+	case ErrUnknownOIDCProvider:
+		return fiber.NewError(fiber.StatusNotFound, err.Error())
+	case ErrInvalidOIDCState, ErrOIDCEmailTaken, ErrIdentityLinked:
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	log.Printf("⚠️ oidc login failed: %v", err)
+	return fiber.NewError(fiber.StatusBadGateway, "login with identity provider failed")
+}
@@ -0,0 +1,28 @@
+package auth
+
+import "time"
+
+// ExternalIdentity links an account at an OpenID Connect provider to a user.
+type ExternalIdentity struct {
+	ID        uint      `gorm:"primaryKey" json:"id"`
+	UserID    uint      `gorm:"index;not null" json:"-"`
+	Provider  string    `gorm:"uniqueIndex:idx_external_identity;size:64;not null" json:"provider"`
+	Subject   string    `gorm:"uniqueIndex:idx_external_identity;size:255;not null" json:"subject"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// OIDCAuthRequest is the server-side state of an authorization code flow
+// between the redirect to the provider and the callback. BrowserHash binds
+// the flow to the browser that started it, see BeginOIDC.
+type OIDCAuthRequest struct {
+	ID           uint   `gorm:"primaryKey"`
+	StateHash    string `gorm:"uniqueIndex;size:64;not null"`
+	BrowserHash  string `gorm:"size:64"`
+	Provider     string `gorm:"size:64;not null"`
+	Nonce        string `gorm:"not null"`
+	CodeVerifier string `gorm:"not null"`
+	LinkUserID   *uint
+	ExpiresAt    time.Time
+	CreatedAt    time.Time
+}
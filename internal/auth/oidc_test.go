@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"unbound/internal/common/mockidp"
+)
+
+const testRedirectURL = "http://app.test/auth/oidc/mock/callback"
+
+func newMockIdP(t *testing.T) (*mockidp.Provider, *httptest.Server) {
+	t.Helper()
+	idp, err := mockidp.New("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := httptest.NewServer(idp)
+	t.Cleanup(srv.Close)
+	idp.Issuer = srv.URL
+	return idp, srv
+}
+
+func newTestOIDCProvider(srv *httptest.Server) *OIDCProvider {
+	return &OIDCProvider{
+		Name:        "mock",
+		Issuer:      srv.URL,
+		ClientID:    "unbound",
+		RedirectURL: testRedirectURL,
+		Scopes:      []string{"openid", "email", "profile"},
+		HTTPClient:  srv.Client(),
+	}
+}
+
+// authorize runs the browser leg of the flow: it opens the authorization
+// URL and returns the query the provider redirected back with.
+func authorize(t *testing.T, srv *httptest.Server, authURL string) url.Values {
+	t.Helper()
+	client := *srv.Client()
+	client.CheckRedirect = func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }
+	resp, err := client.Get(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusFound {
+		t.Fatalf("authorize status = %d, want 302", resp.StatusCode)
+	}
+	loc, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := loc.Scheme + "://" + loc.Host + loc.Path; got != testRedirectURL {
+		t.Fatalf("redirected to %s, want %s", got, testRedirectURL)
+	}
+	return loc.Query()
+}
+
+func pkceChallenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestOIDCFlow(t *testing.T) {
+	_, srv := newMockIdP(t)
+	p := newTestOIDCProvider(srv)
+
+	authURL, err := p.AuthCodeURL("state-1", "nonce-1", pkceChallenge("verifier-1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := authorize(t, srv, authURL+"&login_hint=bob@example.com")
+	if q.Get("state") != "state-1" {
+		t.Fatalf("state = %q, want state-1", q.Get("state"))
+	}
+
+	claims, err := p.Exchange(q.Get("code"), "verifier-1", "nonce-1")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	if claims.Email != "bob@example.com" || !claims.emailVerified() {
+		t.Errorf("email = %q verified %v, want bob@example.com verified", claims.Email, claims.emailVerified())
+	}
+	if claims.Subject != "mock|bob@example.com" {
+		t.Errorf("subject = %q", claims.Subject)
+	}
+	if claims.PreferredUsername != "bob" {
+		t.Errorf("preferred_username = %q, want bob", claims.PreferredUsername)
+	}
+
+	if _, err := p.Exchange(q.Get("code"), "verifier-1", "nonce-1"); err == nil {
+		t.Error("authorization code was accepted twice")
+	}
+}
+
+func TestOIDCExchangeRejects(t *testing.T) {
+	_, srv := newMockIdP(t)
+
+	tests := []struct {
+		name     string
+		verifier string
+		nonce    string
+		clientID string
+	}{
+		{"wrong PKCE verifier", "other-verifier", "nonce-1", "unbound"},
+		{"wrong nonce", "verifier-1", "other-nonce", "unbound"},
+		{"other client", "verifier-1", "nonce-1", "someone-else"},
+	}
+	for _, tt := range tests {
+		p := newTestOIDCProvider(srv)
+		authURL, err := p.AuthCodeURL("state-1", "nonce-1", pkceChallenge("verifier-1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		q := authorize(t, srv, authURL)
+
+		p.ClientID = tt.clientID
+		if _, err := p.Exchange(q.Get("code"), tt.verifier, tt.nonce); err == nil {
+			t.Errorf("%s: Exchange succeeded", tt.name)
+		}
+	}
+}
+
+func TestOIDCIssuerMismatch(t *testing.T) {
+	idp, srv := newMockIdP(t)
+	idp.Issuer = "https://idp.example.com"
+
+	p := newTestOIDCProvider(srv)
+	if _, err := p.AuthCodeURL("state", "nonce", pkceChallenge("verifier")); err == nil {
+		t.Error("discovery accepted a different issuer")
+	}
+}
+
+func TestOIDCTokenFromOtherIssuer(t *testing.T) {
+	_, srv := newMockIdP(t)
+	_, other := newMockIdP(t)
+
+	// Discovery of the real provider, but the token endpoint of another
+	// one: its ID token names another issuer and is signed with a key the
+	// provider does not know.
+	p := newTestOIDCProvider(srv)
+	otherP := newTestOIDCProvider(other)
+	otherURL, err := otherP.AuthCodeURL("state", "nonce", pkceChallenge("verifier"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := p.AuthCodeURL("state", "nonce", pkceChallenge("verifier")); err != nil {
+		t.Fatal(err)
+	}
+	q := authorize(t, other, otherURL)
+
+	p.meta.TokenEndpoint = other.URL + "/token"
+	if _, err := p.Exchange(q.Get("code"), "verifier", "nonce"); err == nil {
+		t.Error("ID token of another issuer was accepted")
+	}
+}
+
+func TestCompleteOIDCRequiresBrowserKey(t *testing.T) {
+	_, srv := newMockIdP(t)
+	s := &AuthService{OIDC: map[string]*OIDCProvider{"mock": newTestOIDCProvider(srv)}}
+
+	if _, _, err := s.CompleteOIDC("mock", "code", "state", "", ClientInfo{}); err != ErrInvalidOIDCState {
+		t.Errorf("CompleteOIDC without browser key = %v, want ErrInvalidOIDCState", err)
+	}
+	if _, _, err := s.CompleteOIDC("other", "code", "state", "key", ClientInfo{}); err != ErrUnknownOIDCProvider {
+		t.Errorf("CompleteOIDC with unknown provider = %v, want ErrUnknownOIDCProvider", err)
+	}
+}
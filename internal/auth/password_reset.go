@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"unbound/internal/common/mailer"
+)
+
+const (
+	passwordResetTTL      = time.Hour
+	passwordResetCooldown = time.Minute
+	minPasswordLength     = 8
+)
+
+var (
+	ErrInvalidResetToken = errors.New("invalid or expired reset token")
+	ErrPasswordTooShort  = fmt.Errorf("password must be at least %d characters", minPasswordLength)
+)
+
+func validatePassword(pw string) error {
+	if len(pw) < minPasswordLength {
+		return ErrPasswordTooShort
+	}
+	return nil
+}
+
+// RequestPasswordReset mails a reset link if the email belongs to an account.
+// It reports success either way so callers cannot probe for registered emails.
+func (s *AuthService) RequestPasswordReset(email string) error {
+	var u User
+	if err := s.DB.Scopes(WhereEmail(email)).First(&u).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	var last PasswordResetToken
+	if err := s.DB.Where("user_id = ?", u.ID).Order("created_at DESC").
+		Limit(1).Find(&last).Error; err == nil && last.ID != 0 &&
+		time.Since(last.CreatedAt) < passwordResetCooldown {
+		return nil
+	}
+
+	token, err := randomHex(32)
+	if err != nil {
+		return err
+	}
+	rt := PasswordResetToken{
+		UserID:    u.ID,
+		TokenHash: s.HashToken(token),
+		ExpiresAt: time.Now().Add(passwordResetTTL),
+	}
+	if err := s.DB.Create(&rt).Error; err != nil {
+		return err
+	}
+
+	// Sent in the background so the response time does not depend on
+	// whether the account exists.
+	go func() {
+		err := s.Mailer.Send(mailer.Message{
+			To:      u.Email,
+			Subject: "Reset password Unbound",
+			Body: fmt.Sprintf("Halo %s,\n\nSeseorang meminta reset password untuk akun kamu. Buka link berikut untuk membuat password baru:\n%s/reset-password?token=%s\n\nAtau kirim token ini ke POST /auth/password/reset:\n%s\n\nLink berlaku 1 jam dan hanya bisa dipakai sekali. Abaikan email ini kalau kamu tidak memintanya.\n",
+				u.Username, s.AppURL, token, token),
+		})
+		if err != nil {
+			log.Printf("⚠️ failed to send password reset email to user %d: %v", u.ID, err)
+		}
+	}()
+	return nil
+}
+
+// ResetPassword consumes a reset token, sets the new password and logs the
+// user out of every session.
+func (s *AuthService) ResetPassword(token, newPassword string) error {
+	if err := validatePassword(newPassword); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(newPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	var userID uint
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		var rt PasswordResetToken
+		if err := tx.Where("token_hash = ? AND used_at IS NULL AND expires_at > ?", s.HashToken(token), time.Now()).
+			First(&rt).Error; err != nil {
+			return ErrInvalidResetToken
+		}
+
+		res := tx.Model(&PasswordResetToken{}).
+			Where("id = ? AND used_at IS NULL", rt.ID).
+			Update("used_at", time.Now())
+		if res.Error != nil {
+			return res.Error
+		}
+		if res.RowsAffected == 0 {
+			return ErrInvalidResetToken
+		}
+
+		if err := tx.Model(&User{}).Where("id = ?", rt.UserID).Update("password", string(hash)).Error; err != nil {
+			return err
+		}
+		userID = rt.UserID
+		return tx.Where("user_id = ? AND used_at IS NULL", rt.UserID).Delete(&PasswordResetToken{}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.RevokeAllSessions(userID)
+}
@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// PATPrefix marks personal access tokens so the middleware can tell them
+// apart from JWTs without trying to parse them.
+const PATPrefix = "ubp_"
+
+const (
+	ScopePostsRead          = "posts:read"
+	ScopePostsWrite         = "posts:write"
+	ScopeFollowsWrite       = "follows:write"
+	ScopeChatsRead          = "chats:read"
+	ScopeChatsWrite         = "chats:write"
+	ScopeNotificationsRead  = "notifications:read"
+	ScopeNotificationsWrite = "notifications:write"
+)
+
+var knownScopes = map[string]bool{
+	ScopePostsRead:          true,
+	ScopePostsWrite:         true,
+	ScopeFollowsWrite:       true,
+	ScopeChatsRead:          true,
+	ScopeChatsWrite:         true,
+	ScopeNotificationsRead:  true,
+	ScopeNotificationsWrite: true,
+}
+
+const patLastUsedGranularity = time.Minute
+
+var (
+	ErrInvalidPAT     = errors.New("invalid access token")
+	ErrPATNotFound    = errors.New("access token not found")
+	ErrPATNameMissing = errors.New("name required")
+)
+
+type CreatePATReq struct {
+	Name          string   `json:"name"`
+	Scopes        []string `json:"scopes"`
+	ExpiresInDays int      `json:"expires_in_days"`
+}
+
+type PATResp struct {
+	ID         uint       `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  *time.Time `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Token      string     `json:"token,omitempty"`
+}
+
+func (t *PersonalAccessToken) ScopeList() []string {
+	return strings.Fields(t.Scopes)
+}
+
+func (t *PersonalAccessToken) resp() PATResp {
+	return PATResp{
+		ID:         t.ID,
+		Name:       t.Name,
+		Prefix:     t.Prefix,
+		Scopes:     t.ScopeList(),
+		ExpiresAt:  t.ExpiresAt,
+		LastUsedAt: t.LastUsedAt,
+		CreatedAt:  t.CreatedAt,
+	}
+}
+
+// CreatePAT issues a new token. The plaintext is only part of this response;
+// afterwards only its hash and display prefix are kept.
+func (s *AuthService) CreatePAT(userID uint, req CreatePATReq) (*PATResp, error) {
+	req.Name = strings.TrimSpace(req.Name)
+	if req.Name == "" {
+		return nil, ErrPATNameMissing
+	}
+	if len(req.Scopes) == 0 {
+		return nil, errors.New("at least one scope required")
+	}
+	seen := make(map[string]bool)
+	scopes := make([]string, 0, len(req.Scopes))
+	for _, sc := range req.Scopes {
+		if !knownScopes[sc] {
+			return nil, errors.New("unknown scope: " + sc)
+		}
+		if !seen[sc] {
+			seen[sc] = true
+			scopes = append(scopes, sc)
+		}
+	}
+	if req.ExpiresInDays < 0 {
+		return nil, errors.New("expires_in_days must be positive")
+	}
+
+	secret, err := randomHex(32)
+	if err != nil {
+		return nil, err
+	}
+	token := PATPrefix + secret
+
+	pat := PersonalAccessToken{
+		UserID:    userID,
+		Name:      req.Name,
+		Prefix:    token[:len(PATPrefix)+8],
+		TokenHash: s.HashToken(token),
+		Scopes:    strings.Join(scopes, " "),
+	}
+	if req.ExpiresInDays > 0 {
+		exp := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		pat.ExpiresAt = &exp
+	}
+	if err := s.DB.Create(&pat).Error; err != nil {
+		return nil, err
+	}
+
+	resp := pat.resp()
+	resp.Token = token
+	return &resp, nil
+}
+
+func (s *AuthService) ListPATs(userID uint) ([]PATResp, error) {
+	var pats []PersonalAccessToken
+	if err := s.DB.Where("user_id = ?", userID).Order("created_at DESC").Find(&pats).Error; err != nil {
+		return nil, err
+	}
+	resp := make([]PATResp, 0, len(pats))
+	for i := range pats {
+		resp = append(resp, pats[i].resp())
+	}
+	return resp, nil
+}
+
+func (s *AuthService) RevokePAT(userID, id uint) error {
+	res := s.DB.Where("id = ? AND user_id = ?", id, userID).Delete(&PersonalAccessToken{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrPATNotFound
+	}
+	return nil
+}
+
+// AuthenticatePAT resolves a token to its record. Tokens stop working once
+// their owner is deactivated, scheduled for deletion or gone, and when they
+// were created before the user's last "log out everywhere".
+func (s *AuthService) AuthenticatePAT(token string) (*PersonalAccessToken, error) {
+	var pat PersonalAccessToken
+	err := s.DB.Model(&PersonalAccessToken{}).
+		Joins(`JOIN users u ON u.id = personal_access_tokens.user_id AND u.deleted_at IS NULL
+			AND u.deactivated_at IS NULL AND u.deletion_scheduled_at IS NULL`).
+		Joins("LEFT JOIN token_cutoffs tc ON tc.user_id = personal_access_tokens.user_id").
+		Where("personal_access_tokens.token_hash = ?", s.HashToken(token)).
+		Where("tc.not_before IS NULL OR personal_access_tokens.created_at >= tc.not_before").
+		Select("personal_access_tokens.*").
+		Take(&pat).Error
+	if err != nil {
+		return nil, ErrInvalidPAT
+	}
+	now := time.Now()
+	if pat.ExpiresAt != nil && now.After(*pat.ExpiresAt) {
+		return nil, ErrInvalidPAT
+	}
+
+	if pat.LastUsedAt == nil || now.Sub(*pat.LastUsedAt) > patLastUsedGranularity {
+		s.DB.Model(&pat).Update("last_used_at", now)
+	}
+	return &pat, nil
+}
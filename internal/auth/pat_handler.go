@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// RegisterPATRoutes mounts personal access token management. protected must
+// only accept interactive sessions so a token cannot mint more tokens.
+func RegisterPATRoutes(app *fiber.App, svc *AuthService, protected fiber.Handler) {
+	r := app.Group("/auth/tokens", protected)
+
+	r.Get("/", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+
+		pats, err := svc.ListPATs(userID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch tokens")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    pats,
+		})
+	})
+
+	r.Post("/", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		var req CreatePATReq
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid body")
+		}
+
+		pat, err := svc.CreatePAT(userID, req)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"success": true,
+			"message": "Copy this token now, it will not be shown again",
+			"data":    pat,
+		})
+	})
+
+	r.Delete("/:id", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid token id")
+		}
+
+		if err := svc.RevokePAT(userID, uint(id)); err != nil {
+			if err == ErrPATNotFound {
+				return fiber.NewError(fiber.StatusNotFound, err.Error())
+			}
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to revoke token")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Token revoked",
+		})
+	})
+}
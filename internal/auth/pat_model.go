@@ -0,0 +1,15 @@
+package auth
+
+import "time"
+
+type PersonalAccessToken struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"index;not null"`
+	Name       string `gorm:"not null"`
+	Prefix     string `gorm:"size:16;not null"`
+	TokenHash  string `gorm:"uniqueIndex;size:64;not null"`
+	Scopes     string `gorm:"not null"`
+	ExpiresAt  *time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPersonalAccessTokens(t *testing.T) {
+	s := testAuthService(t)
+	u := createTestUser(t, s)
+
+	if _, err := s.CreatePAT(u.ID, CreatePATReq{Name: "ci", Scopes: []string{"admin:all"}}); err == nil {
+		t.Error("CreatePAT accepted an unknown scope")
+	}
+
+	created, err := s.CreatePAT(u.ID, CreatePATReq{Name: "ci", Scopes: []string{ScopePostsRead, ScopePostsRead, ScopePostsWrite}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	pat, err := s.AuthenticatePAT(created.Token)
+	if err != nil {
+		t.Fatalf("AuthenticatePAT: %v", err)
+	}
+	if got := pat.ScopeList(); len(got) != 2 || got[0] != ScopePostsRead || got[1] != ScopePostsWrite {
+		t.Errorf("scopes = %v, want [posts:read posts:write]", got)
+	}
+	if _, err := s.AuthenticatePAT(created.Token + "x"); err != ErrInvalidPAT {
+		t.Errorf("wrong token: err = %v, want ErrInvalidPAT", err)
+	}
+
+	// Logging out everywhere takes personal access tokens with it.
+	if err := s.RevokeAllSessions(u.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AuthenticatePAT(created.Token); err != ErrInvalidPAT {
+		t.Errorf("after RevokeAllSessions: err = %v, want ErrInvalidPAT", err)
+	}
+
+	fresh, err := s.CreatePAT(u.ID, CreatePATReq{Name: "new", Scopes: []string{ScopePostsRead}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AuthenticatePAT(fresh.Token); err != nil {
+		t.Errorf("token created after the cutoff: %v", err)
+	}
+
+	if err := s.DB.Model(&User{}).Where("id = ?", u.ID).Update("deactivated_at", time.Now()).Error; err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.AuthenticatePAT(fresh.Token); err != ErrInvalidPAT {
+		t.Errorf("deactivated user: err = %v, want ErrInvalidPAT", err)
+	}
+}
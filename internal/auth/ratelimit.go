@@ -0,0 +1,115 @@
+package auth
+
+import (
+	"os"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Limiter locks a key out once it reaches Threshold failures within Window.
+// Every further failure doubles the lockout, up to MaxLockout.
+type Limiter struct {
+	Store       AttemptStore
+	Prefix      string
+	Threshold   int
+	Window      time.Duration
+	BaseLockout time.Duration
+	MaxLockout  time.Duration
+}
+
+// RetryAfter reports how long key is still locked out, or zero.
+func (l *Limiter) RetryAfter(key string) (time.Duration, error) {
+	a, err := l.Store.Get(l.Prefix + key)
+	if err != nil {
+		return 0, err
+	}
+	if wait := time.Until(a.LockedUntil); wait > 0 {
+		return wait, nil
+	}
+	return 0, nil
+}
+
+func (l *Limiter) Fail(key string) (time.Duration, error) {
+	now := time.Now()
+	a, err := l.Store.RecordFailure(l.Prefix+key, now, l.Window)
+	if err != nil {
+		return 0, err
+	}
+	if a.Failures < l.Threshold {
+		return 0, nil
+	}
+
+	lockout := l.BaseLockout
+	for i := l.Threshold; i < a.Failures && lockout < l.MaxLockout; i++ {
+		lockout *= 2
+	}
+	if lockout > l.MaxLockout {
+		lockout = l.MaxLockout
+	}
+	return lockout, l.Store.Lock(l.Prefix+key, now.Add(lockout))
+}
+
+func (l *Limiter) Reset(key string) error {
+	return l.Store.Reset(l.Prefix + key)
+}
+
+// BruteForceGuard bundles the limits applied to the public auth endpoints.
+type BruteForceGuard struct {
+	LoginAccount    *Limiter
+	LoginIP         *Limiter
+	RegisterIP      *Limiter
+	PasswordResetIP *Limiter
+}
+
+// NewBruteForceGuard keeps counters in memory unless RATE_LIMIT_STORE is
+// "postgres", which is needed when running more than one instance.
+func NewBruteForceGuard(db *gorm.DB) *BruteForceGuard {
+	var store AttemptStore = NewMemoryAttemptStore()
+	if os.Getenv("RATE_LIMIT_STORE") == "postgres" {
+		store = &PostgresAttemptStore{DB: db}
+	}
+
+	return &BruteForceGuard{
+		LoginAccount: &Limiter{
+			Store:       store,
+			Prefix:      "login:account:",
+			Threshold:   5,
+			Window:      15 * time.Minute,
+			BaseLockout: 30 * time.Second,
+			MaxLockout:  30 * time.Minute,
+		},
+		LoginIP: &Limiter{
+			Store:       store,
+			Prefix:      "login:ip:",
+			Threshold:   20,
+			Window:      15 * time.Minute,
+			BaseLockout: time.Minute,
+			MaxLockout:  time.Hour,
+		},
+		RegisterIP: &Limiter{
+			Store:       store,
+			Prefix:      "register:ip:",
+			Threshold:   5,
+			Window:      time.Hour,
+			BaseLockout: 10 * time.Minute,
+			MaxLockout:  24 * time.Hour,
+		},
+		// Every reset request sends an email, so an unlimited endpoint could
+		// flood an inbox or burn the mail provider quota.
+		PasswordResetIP: &Limiter{
+			Store:       store,
+			Prefix:      "reset:ip:",
+			Threshold:   5,
+			Window:      time.Hour,
+			BaseLockout: 10 * time.Minute,
+			MaxLockout:  24 * time.Hour,
+		},
+	}
+}
+
+// AccountKey is the limiter key for login failures of an address. It uses
+// the canonical form so spelling variants of one address share a counter.
+func AccountKey(email string) string {
+	return CanonicalEmail(email)
+}
@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiterLockout(t *testing.T) {
+	l := &Limiter{
+		Store:       NewMemoryAttemptStore(),
+		Prefix:      "test:",
+		Threshold:   3,
+		Window:      time.Minute,
+		BaseLockout: 10 * time.Second,
+		MaxLockout:  40 * time.Second,
+	}
+
+	// The lockout starts at the threshold and doubles up to the maximum.
+	want := []time.Duration{0, 0, 10 * time.Second, 20 * time.Second, 40 * time.Second, 40 * time.Second}
+	for i, w := range want {
+		got, err := l.Fail("alice@example.com")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != w {
+			t.Errorf("failure %d: lockout = %v, want %v", i+1, got, w)
+		}
+	}
+
+	if wait, _ := l.RetryAfter("alice@example.com"); wait <= 0 || wait > 40*time.Second {
+		t.Errorf("RetryAfter = %v, want up to 40s", wait)
+	}
+	if wait, _ := l.RetryAfter("bob@example.com"); wait != 0 {
+		t.Errorf("RetryAfter of another key = %v, want 0", wait)
+	}
+
+	if err := l.Reset("alice@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if wait, _ := l.RetryAfter("alice@example.com"); wait != 0 {
+		t.Errorf("RetryAfter after Reset = %v, want 0", wait)
+	}
+	if got, _ := l.Fail("alice@example.com"); got != 0 {
+		t.Errorf("first failure after Reset: lockout = %v, want 0", got)
+	}
+}
+
+func TestMemoryAttemptStoreWindow(t *testing.T) {
+	s := NewMemoryAttemptStore()
+	start := time.Now()
+	window := time.Minute
+
+	tests := []struct {
+		at   time.Duration
+		want int
+	}{
+		{0, 1},
+		{30 * time.Second, 2},
+		{80 * time.Second, 3},
+		// More than a window after the last failure, counting starts over.
+		{150 * time.Second, 1},
+	}
+	for _, tt := range tests {
+		a, err := s.RecordFailure("key", start.Add(tt.at), window)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if a.Failures != tt.want {
+			t.Errorf("failure at +%v: count = %d, want %d", tt.at, a.Failures, tt.want)
+		}
+	}
+}
+
+func TestMemoryAttemptStoreSweepKeepsLongerWindows(t *testing.T) {
+	s := NewMemoryAttemptStore()
+	start := time.Now()
+
+	if _, err := s.RecordFailure("long", start, time.Hour); err != nil {
+		t.Fatal(err)
+	}
+	// A limiter with a short window triggers the sweep; it must not drop
+	// the counter of the limiter with the longer one.
+	if _, err := s.RecordFailure("short", start.Add(10*time.Minute), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if a, _ := s.Get("long"); a.Failures != 1 {
+		t.Errorf("failures of the long window after sweep = %d, want 1", a.Failures)
+	}
+}
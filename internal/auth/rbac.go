@@ -0,0 +1,326 @@
+package auth
+
+import (
+	"errors"
+	"log"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+const (
+	PermAll              = "*"
+	PermUsersRead        = "users.read"
+	PermUsersManage      = "users.manage"
+	PermRolesManage      = "roles.manage"
+	PermPostsModerate    = "posts.moderate"
+	PermCommentsModerate = "comments.moderate"
+)
+
+var knownPermissions = map[string]bool{
+	PermAll:              true,
+	PermUsersRead:        true,
+	PermUsersManage:      true,
+	PermRolesManage:      true,
+	PermPostsModerate:    true,
+	PermCommentsModerate: true,
+}
+
+var builtInRoles = map[string][]string{
+	RoleUser:      {},
+	RoleModerator: {PermUsersRead, PermPostsModerate, PermCommentsModerate},
+	RoleAdmin:     {PermAll},
+}
+
+const roleCacheTTL = 30 * time.Second
+
+var roleNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{1,63}$`)
+
+var (
+	ErrRoleNotFound      = errors.New("role not found")
+	ErrRoleExists        = errors.New("role already exists")
+	ErrRoleBuiltIn       = errors.New("built-in roles cannot be changed")
+	ErrRoleInUse         = errors.New("role is still assigned to users")
+	ErrInvalidRoleName   = errors.New("role name must be 2-64 lowercase letters, digits, - or _")
+	ErrUnknownPermission = errors.New("unknown permission")
+	ErrLastAdmin         = errors.New("cannot remove the last admin")
+	ErrPermissionNotHeld = errors.New("cannot grant permissions you do not have")
+)
+
+type RoleResp struct {
+	Role
+	Permissions []string `json:"permissions"`
+}
+
+func (r Role) PermissionList() []string {
+	return strings.Fields(r.Permissions)
+}
+
+// roleCache keeps role permissions in memory. Roles change rarely, so a
+// short TTL is enough to pick up changes made by other instances.
+type roleCache struct {
+	mu       sync.RWMutex
+	perms    map[string]map[string]bool
+	loadedAt time.Time
+}
+
+func (s *AuthService) rolePermissions(role string) (map[string]bool, error) {
+	s.roles.mu.RLock()
+	fresh := time.Since(s.roles.loadedAt) < roleCacheTTL
+	perms := s.roles.perms[role]
+	s.roles.mu.RUnlock()
+	if fresh {
+		return perms, nil
+	}
+
+	var roles []Role
+	if err := s.DB.Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	all := make(map[string]map[string]bool, len(roles))
+	for _, r := range roles {
+		set := make(map[string]bool)
+		for _, p := range r.PermissionList() {
+			set[p] = true
+		}
+		all[r.Name] = set
+	}
+
+	s.roles.mu.Lock()
+	s.roles.perms = all
+	s.roles.loadedAt = time.Now()
+	s.roles.mu.Unlock()
+	return all[role], nil
+}
+
+func (s *AuthService) invalidateRoles() {
+	s.roles.mu.Lock()
+	s.roles.loadedAt = time.Time{}
+	s.roles.mu.Unlock()
+}
+
+func (s *AuthService) HasPermission(userID uint, perm string) (bool, error) {
+	var u User
+	if err := s.DB.Select("id", "role").First(&u, userID).Error; err != nil {
+		return false, err
+	}
+	perms, err := s.rolePermissions(u.Role)
+	if err != nil {
+		return false, err
+	}
+	return perms[PermAll] || perms[perm], nil
+}
+
+// EnsureRoles creates the built-in roles and, if BOOTSTRAP_ADMIN_EMAIL is set
+// and there is no admin yet, promotes that account to admin. Only a verified
+// address is promoted, otherwise anyone could register it before the owner.
+func (s *AuthService) EnsureRoles() error {
+	for name, perms := range builtInRoles {
+		r := Role{Name: name, Permissions: strings.Join(perms, " "), BuiltIn: true}
+		if err := s.DB.Where(Role{Name: name}).FirstOrCreate(&r).Error; err != nil {
+			return err
+		}
+	}
+
+	email := os.Getenv("BOOTSTRAP_ADMIN_EMAIL")
+	if email == "" {
+		return nil
+	}
+	var admins int64
+	if err := s.DB.Model(&User{}).Where("role = ?", RoleAdmin).Count(&admins).Error; err != nil {
+		return err
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	res := s.DB.Model(&User{}).
+		Where("email_canonical = ? AND email_verified_at IS NOT NULL", CanonicalEmail(email)).
+		Update("role", RoleAdmin)
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		log.Printf("⚠️ BOOTSTRAP_ADMIN_EMAIL %s does not belong to a verified account yet, register it, verify the email and restart", email)
+	} else {
+		log.Printf("👑 %s is now admin", email)
+	}
+	return nil
+}
+
+func (s *AuthService) ListRoles() ([]RoleResp, error) {
+	var roles []Role
+	if err := s.DB.Order("name ASC").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	resp := make([]RoleResp, 0, len(roles))
+	for _, r := range roles {
+		resp = append(resp, RoleResp{Role: r, Permissions: r.PermissionList()})
+	}
+	return resp, nil
+}
+
+func normalizePermissions(perms []string) (string, error) {
+	set := make(map[string]bool)
+	for _, p := range perms {
+		if !knownPermissions[p] {
+			return "", ErrUnknownPermission
+		}
+		set[p] = true
+	}
+	list := make([]string, 0, len(set))
+	for p := range set {
+		list = append(list, p)
+	}
+	sort.Strings(list)
+	return strings.Join(list, " "), nil
+}
+
+// checkGrant makes sure actorID holds every permission in perms, so nobody
+// can hand out more than they have. Only holders of PermAll may grant it.
+func (s *AuthService) checkGrant(actorID uint, perms []string) error {
+	var actor User
+	if err := s.DB.Select("id", "role").First(&actor, actorID).Error; err != nil {
+		return err
+	}
+	held, err := s.rolePermissions(actor.Role)
+	if err != nil {
+		return err
+	}
+	if held[PermAll] {
+		return nil
+	}
+	for _, p := range perms {
+		if !held[p] {
+			return ErrPermissionNotHeld
+		}
+	}
+	return nil
+}
+
+func (s *AuthService) CreateRole(actorID uint, name string, perms []string) (*RoleResp, error) {
+	if !roleNamePattern.MatchString(name) {
+		return nil, ErrInvalidRoleName
+	}
+	joined, err := normalizePermissions(perms)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkGrant(actorID, strings.Fields(joined)); err != nil {
+		return nil, err
+	}
+
+	var count int64
+	s.DB.Model(&Role{}).Where("name = ?", name).Count(&count)
+	if count > 0 {
+		return nil, ErrRoleExists
+	}
+
+	r := Role{Name: name, Permissions: joined}
+	if err := s.DB.Create(&r).Error; err != nil {
+		return nil, err
+	}
+	s.invalidateRoles()
+	return &RoleResp{Role: r, Permissions: r.PermissionList()}, nil
+}
+
+// UpdateRolePermissions replaces the permissions of a custom role. The actor
+// must hold both the old and the new permissions, otherwise they could strip
+// a role above their own.
+func (s *AuthService) UpdateRolePermissions(actorID uint, name string, perms []string) (*RoleResp, error) {
+	var r Role
+	if err := s.DB.Where("name = ?", name).First(&r).Error; err != nil {
+		return nil, ErrRoleNotFound
+	}
+	if r.BuiltIn {
+		return nil, ErrRoleBuiltIn
+	}
+	joined, err := normalizePermissions(perms)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.checkGrant(actorID, append(r.PermissionList(), strings.Fields(joined)...)); err != nil {
+		return nil, err
+	}
+
+	if err := s.DB.Model(&r).Update("permissions", joined).Error; err != nil {
+		return nil, err
+	}
+	s.invalidateRoles()
+	return &RoleResp{Role: r, Permissions: r.PermissionList()}, nil
+}
+
+func (s *AuthService) DeleteRole(name string) error {
+	var r Role
+	if err := s.DB.Where("name = ?", name).First(&r).Error; err != nil {
+		return ErrRoleNotFound
+	}
+	if r.BuiltIn {
+		return ErrRoleBuiltIn
+	}
+
+	var users int64
+	if err := s.DB.Model(&User{}).Where("role = ?", name).Count(&users).Error; err != nil {
+		return err
+	}
+	if users > 0 {
+		return ErrRoleInUse
+	}
+
+	if err := s.DB.Delete(&r).Error; err != nil {
+		return err
+	}
+	s.invalidateRoles()
+	return nil
+}
+
+// AssignRole gives userID the role. The actor must hold every permission of
+// both the new role and the user's current one, so only admins can make or
+// unmake admins. Admin rows are locked while the last-admin check runs, so two
+// admins cannot demote each other at the same time.
+func (s *AuthService) AssignRole(actorID, userID uint, role string) error {
+	var r Role
+	if err := s.DB.Where("name = ?", role).First(&r).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRoleNotFound
+		}
+		return err
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var admins []uint
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Model(&User{}).
+			Where("role = ?", RoleAdmin).Pluck("id", &admins).Error; err != nil {
+			return err
+		}
+		var u User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "role").First(&u, userID).Error; err != nil {
+			return err
+		}
+
+		var current Role
+		if err := tx.Where("name = ?", u.Role).Limit(1).Find(&current).Error; err != nil {
+			return err
+		}
+		if err := s.checkGrant(actorID, append(r.PermissionList(), current.PermissionList()...)); err != nil {
+			return err
+		}
+
+		if u.Role == RoleAdmin && role != RoleAdmin && len(admins) <= 1 {
+			return ErrLastAdmin
+		}
+		return tx.Model(&u).Update("role", role).Error
+	})
+}
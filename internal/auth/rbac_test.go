@@ -0,0 +1,58 @@
+package auth
+
+import "testing"
+
+func TestRoleGrants(t *testing.T) {
+	s := testAuthService(t)
+	if err := s.EnsureRoles(); err != nil {
+		t.Fatal(err)
+	}
+	suffix, err := randomHex(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manager := Role{Name: "manager-" + suffix, Permissions: PermRolesManage + " " + PermUsersRead}
+	if err := s.DB.Create(&manager).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	mgr := createTestUser(t, s)
+	admin := createTestUser(t, s)
+	target := createTestUser(t, s)
+	must(t, s.DB.Model(mgr).Update("role", manager.Name).Error)
+	must(t, s.DB.Model(admin).Update("role", RoleAdmin).Error)
+	s.invalidateRoles()
+
+	if _, err := s.CreateRole(mgr.ID, "wildcard-"+suffix, []string{PermAll}); err != ErrPermissionNotHeld {
+		t.Errorf("manager creating a role with *: err = %v, want ErrPermissionNotHeld", err)
+	}
+	if _, err := s.CreateRole(mgr.ID, "mods-"+suffix, []string{PermPostsModerate}); err != ErrPermissionNotHeld {
+		t.Errorf("manager granting a permission it lacks: err = %v, want ErrPermissionNotHeld", err)
+	}
+	if _, err := s.CreateRole(mgr.ID, "readers-"+suffix, []string{PermUsersRead}); err != nil {
+		t.Errorf("manager granting its own permission: %v", err)
+	}
+	if _, err := s.UpdateRolePermissions(mgr.ID, "readers-"+suffix, []string{PermAll}); err != ErrPermissionNotHeld {
+		t.Errorf("manager widening a role to *: err = %v, want ErrPermissionNotHeld", err)
+	}
+
+	if err := s.AssignRole(mgr.ID, target.ID, RoleAdmin); err != ErrPermissionNotHeld {
+		t.Errorf("manager assigning admin: err = %v, want ErrPermissionNotHeld", err)
+	}
+	if err := s.AssignRole(mgr.ID, admin.ID, RoleUser); err != ErrPermissionNotHeld {
+		t.Errorf("manager demoting an admin: err = %v, want ErrPermissionNotHeld", err)
+	}
+	if err := s.AssignRole(mgr.ID, target.ID, "readers-"+suffix); err != nil {
+		t.Errorf("manager assigning a smaller role: %v", err)
+	}
+	if err := s.AssignRole(admin.ID, target.ID, RoleAdmin); err != nil {
+		t.Errorf("admin assigning admin: %v", err)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
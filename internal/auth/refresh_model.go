@@ -1,10 +1,49 @@
 package auth
 
-import "time"
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
 
 type RefreshToken struct {
-	ID        uint      `gorm:"primaryKey"`
-	UserID    uint
-	Token     string    `gorm:"uniqueIndex"`
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index"`
+	FamilyID  string `gorm:"index;size:64"`
+	TokenHash string `gorm:"uniqueIndex;size:64"`
 	ExpiresAt time.Time
+	RotatedAt *time.Time
+	CreatedAt time.Time
+}
+
+// MigrateLegacyRefreshTokens hashes refresh tokens that older versions stored
+// in plaintext in the "token" column and then drops that column. It must run
+// before AutoMigrate and is a no-op once the column is gone.
+func MigrateLegacyRefreshTokens(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&RefreshToken{}) || !m.HasColumn(&RefreshToken{}, "token") {
+		return nil
+	}
+
+	key := tokenHashKey()
+	return db.Transaction(func(tx *gorm.DB) error {
+		var rows []struct {
+			ID    uint
+			Token string
+		}
+		if err := tx.Raw(`SELECT id, token FROM refresh_tokens WHERE token IS NOT NULL`).Scan(&rows).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Exec(`ALTER TABLE refresh_tokens ADD COLUMN IF NOT EXISTS token_hash varchar(64)`).Error; err != nil {
+			return err
+		}
+		for _, r := range rows {
+			if err := tx.Exec(`UPDATE refresh_tokens SET token_hash = ? WHERE id = ?`, hashToken(key, r.Token), r.ID).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Migrator().DropColumn(&RefreshToken{}, "token")
+	})
 }
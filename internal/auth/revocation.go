@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const revocationSyncInterval = 30 * time.Second
+
+// RevokedToken is a denylist entry for access tokens. Key is either
+// "jti:<token id>" for a single token or "sid:<session id>" for every token
+// of a session. Entries are only needed until the tokens would have expired.
+type RevokedToken struct {
+	ID        uint      `gorm:"primaryKey"`
+	Key       string    `gorm:"uniqueIndex;size:80;not null"`
+	UserID    uint      `gorm:"index"`
+	ExpiresAt time.Time `gorm:"index"`
+	CreatedAt time.Time
+}
+
+// TokenCutoff rejects every access token of a user issued before NotBefore.
+type TokenCutoff struct {
+	UserID    uint `gorm:"primaryKey;autoIncrement:false"`
+	NotBefore time.Time
+	UpdatedAt time.Time
+}
+
+// RevocationStore keeps the denylist and cutoffs in Postgres and mirrors them
+// in memory so token checks never hit the database. Run keeps the mirror in
+// sync with revocations made by other instances.
+type RevocationStore struct {
+	db      *gorm.DB
+	mu      sync.RWMutex
+	keys    map[string]time.Time
+	cutoffs map[uint]time.Time
+}
+
+func NewRevocationStore(db *gorm.DB) *RevocationStore {
+	r := &RevocationStore{
+		db:      db,
+		keys:    make(map[string]time.Time),
+		cutoffs: make(map[uint]time.Time),
+	}
+	if err := r.load(); err != nil {
+		log.Printf("⚠️ failed to load token revocations: %v", err)
+	}
+	return r
+}
+
+func (r *RevocationStore) Run() {
+	ticker := time.NewTicker(revocationSyncInterval)
+	defer ticker.Stop()
+	for range ticker.C {
+		r.db.Where("expires_at < ?", time.Now()).Delete(&RevokedToken{})
+		if err := r.load(); err != nil {
+			log.Printf("⚠️ failed to sync token revocations: %v", err)
+		}
+	}
+}
+
+// load merges the entries in Postgres into the mirror. Entries are merged
+// rather than swapped in, so a revocation recorded locally while the query
+// ran is not lost; entries that no longer matter are dropped.
+func (r *RevocationStore) load() error {
+	now := time.Now()
+	var revoked []RevokedToken
+	if err := r.db.Where("expires_at > ?", now).Find(&revoked).Error; err != nil {
+		return err
+	}
+	var cutoffs []TokenCutoff
+	if err := r.db.Where("not_before > ?", now.Add(-accessTokenTTL)).Find(&cutoffs).Error; err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, rt := range revoked {
+		r.keys[rt.Key] = rt.ExpiresAt
+	}
+	for _, c := range cutoffs {
+		if c.NotBefore.After(r.cutoffs[c.UserID]) {
+			r.cutoffs[c.UserID] = c.NotBefore
+		}
+	}
+	for k, exp := range r.keys {
+		if exp.Before(now) {
+			delete(r.keys, k)
+		}
+	}
+	for uid, t := range r.cutoffs {
+		if t.Before(now.Add(-accessTokenTTL)) {
+			delete(r.cutoffs, uid)
+		}
+	}
+	return nil
+}
+
+func (r *RevocationStore) revoke(keys []string, userID uint) error {
+	if len(keys) == 0 {
+		return nil
+	}
+	expires := time.Now().Add(accessTokenTTL)
+	rows := make([]RevokedToken, 0, len(keys))
+	for _, k := range keys {
+		rows = append(rows, RevokedToken{Key: k, UserID: userID, ExpiresAt: expires})
+	}
+	if err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error; err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	for _, k := range keys {
+		r.keys[k] = expires
+	}
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *RevocationStore) RevokeToken(jti string, userID uint) error {
+	return r.revoke([]string{"jti:" + jti}, userID)
+}
+
+func (r *RevocationStore) RevokeSessions(familyIDs []string, userID uint) error {
+	keys := make([]string, 0, len(familyIDs))
+	for _, id := range familyIDs {
+		keys = append(keys, "sid:"+id)
+	}
+	return r.revoke(keys, userID)
+}
+
+// RevokeUserBefore invalidates every access token of the user issued before t.
+// Access tokens carry their issue time in whole seconds, so IsRevoked also
+// rejects those issued in the same second as t: they may predate it.
+func (r *RevocationStore) RevokeUserBefore(userID uint, t time.Time) error {
+	cutoff := TokenCutoff{UserID: userID, NotBefore: t}
+	if err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"not_before", "updated_at"}),
+	}).Create(&cutoff).Error; err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	r.cutoffs[userID] = t
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *RevocationStore) IsRevoked(c *Claims) bool {
+	uid, _ := c.UserID()
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if c.ID != "" {
+		if _, ok := r.keys["jti:"+c.ID]; ok {
+			return true
+		}
+	}
+	if c.SessionID != "" {
+		if _, ok := r.keys["sid:"+c.SessionID]; ok {
+			return true
+		}
+	}
+	if cutoff, ok := r.cutoffs[uid]; ok {
+		if c.IssuedAt == nil || !c.IssuedAt.Time.After(cutoff.Truncate(time.Second)) {
+			return true
+		}
+	}
+	return false
+}
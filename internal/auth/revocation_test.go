@@ -0,0 +1,112 @@
+package auth
+
+import (
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// dryRunDB builds statements without ever talking to a database, which is
+// enough for code paths that only write.
+func dryRunDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:                 true,
+		SkipDefaultTransaction: true,
+		DisableAutomaticPing:   true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+func testClaims(userID uint, jti, sid string, iat *time.Time) *Claims {
+	c := &Claims{
+		SessionID: sid,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:      jti,
+			Subject: strconv.FormatUint(uint64(userID), 10),
+		},
+	}
+	if iat != nil {
+		c.IssuedAt = jwt.NewNumericDate(*iat)
+	}
+	return c
+}
+
+func TestIsRevokedCutoff(t *testing.T) {
+	r := &RevocationStore{
+		db:      dryRunDB(t),
+		keys:    make(map[string]time.Time),
+		cutoffs: make(map[uint]time.Time),
+	}
+	// Issue times are whole seconds, so a token stamped with the second of
+	// the cutoff may have been issued before it and must be rejected.
+	cutoff := time.Date(2026, 3, 1, 12, 0, 0, 500_000_000, time.UTC)
+	if err := r.RevokeUserBefore(1, cutoff); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RevokeUserBefore(3, cutoff.Truncate(time.Second)); err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(d time.Duration) *time.Time {
+		t := cutoff.Truncate(time.Second).Add(d)
+		return &t
+	}
+	tests := []struct {
+		name   string
+		userID uint
+		iat    *time.Time
+		want   bool
+	}{
+		{"issued a second before the cutoff", 1, at(-time.Second), true},
+		{"issued long before the cutoff", 1, at(-time.Hour), true},
+		{"issued in the cutoff second", 1, at(0), true},
+		{"issued after the cutoff second", 1, at(time.Second), false},
+		{"issued in a cutoff on a whole second", 3, at(0), true},
+		{"issued right after a cutoff on a whole second", 3, at(time.Second), false},
+		{"without issued at", 1, nil, true},
+		{"other user", 2, at(-time.Hour), false},
+	}
+	for _, tt := range tests {
+		if got := r.IsRevoked(testClaims(tt.userID, "jti", "sid", tt.iat)); got != tt.want {
+			t.Errorf("%s: IsRevoked = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsRevokedKeys(t *testing.T) {
+	r := &RevocationStore{
+		db:      dryRunDB(t),
+		keys:    make(map[string]time.Time),
+		cutoffs: make(map[uint]time.Time),
+	}
+	if err := r.RevokeToken("old-jti", 1); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.RevokeSessions([]string{"old-session"}, 1); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+	tests := []struct {
+		name     string
+		jti, sid string
+		want     bool
+	}{
+		{"revoked token", "old-jti", "session", true},
+		{"revoked session", "jti", "old-session", true},
+		{"other token and session", "jti", "session", false},
+	}
+	for _, tt := range tests {
+		if got := r.IsRevoked(testClaims(1, tt.jti, tt.sid, &now)); got != tt.want {
+			t.Errorf("%s: IsRevoked = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,12 @@
+package auth
+
+import "time"
+
+type Role struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	Name        string    `gorm:"uniqueIndex;size:64;not null" json:"name"`
+	Permissions string    `gorm:"not null;default:''" json:"-"`
+	BuiltIn     bool      `gorm:"not null;default:false" json:"built_in"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
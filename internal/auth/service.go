@@ -1,29 +1,95 @@
 package auth
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"errors"
+	"log"
 	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
+	"unbound/internal/common/mailer"
 )
 
+var (
+	ErrInvalidCredentials  = errors.New("invalid credentials")
+	ErrInvalidRefreshToken = errors.New("invalid refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected, please login again")
+	ErrSessionNotFound     = errors.New("session not found")
+	ErrTokenRevoked        = errors.New("token has been revoked")
+)
+
+const accessTokenTTL = 24 * time.Hour
+
 type AuthService struct {
-	DB        *gorm.DB
-	JWTSecret []byte
+	DB          *gorm.DB
+	Keys        *KeySet
+	TokenKey    []byte
+	Revocations *RevocationStore
+	Mailer      mailer.Mailer
+	AppURL      string
+	Guard       *BruteForceGuard
+	OIDC        map[string]*OIDCProvider
+
+	roles roleCache
 }
 
 func NewAuthService(db *gorm.DB) *AuthService {
-	secret := os.Getenv("JWT_SECRET")
-	if secret == "" {
-		secret = "dev-secret"
+	keys, err := LoadKeySet()
+	if err != nil {
+		log.Fatalf("❌ Failed to load JWT signing keys: %v", err)
+	}
+	base := appURL()
+	return &AuthService{
+		DB:          db,
+		Keys:        keys,
+		TokenKey:    tokenHashKey(),
+		Revocations: NewRevocationStore(db),
+		Mailer:      mailer.FromEnv(),
+		AppURL:      base,
+		Guard:       NewBruteForceGuard(db),
+		OIDC:        LoadOIDCProviders(base),
+	}
+}
+
+func appURL() string {
+	if u := os.Getenv("APP_URL"); u != "" {
+		return strings.TrimSuffix(u, "/")
 	}
-	return &AuthService{DB: db, JWTSecret: []byte(secret)}
+	return "http://localhost:8080"
+}
+
+// tokenHashKey is the HMAC key used to hash opaque tokens before they are
+// stored. JWT_SECRET is still accepted because that is what earlier versions
+// hashed refresh tokens with.
+func tokenHashKey() []byte {
+	if key := os.Getenv("TOKEN_HASH_KEY"); key != "" {
+		return []byte(key)
+	}
+	if key := os.Getenv("JWT_SECRET"); key != "" {
+		return []byte(key)
+	}
+	log.Fatal("❌ TOKEN_HASH_KEY is not set")
+	return nil
+}
+
+func hashToken(key []byte, token string) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(token))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// HashToken is how opaque tokens are stored, for tokens issued outside this
+// package too.
+func (s *AuthService) HashToken(token string) string {
+	return hashToken(s.TokenKey, token)
 }
 
 type RegisterReq struct {
@@ -33,8 +99,14 @@ type RegisterReq struct {
 }
 
 type LoginReq struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email      string `json:"email"`
+	Password   string `json:"password"`
+	DeviceName string `json:"device_name"`
+}
+
+type Claims struct {
+	SessionID string `json:"sid,omitempty"`
+	jwt.RegisteredClaims
 }
 
 type TokenResp struct {
@@ -42,15 +114,28 @@ type TokenResp struct {
 	RefreshToken string `json:"refresh_token"`
 }
 
+// LoginResult carries either the issued tokens or, for accounts with
+// two-factor authentication, the challenge token for CompleteMFALogin.
+type LoginResult struct {
+	Tokens   *TokenResp
+	MFAToken string
+}
+
 func (s *AuthService) Register(input RegisterReq) (*User, error) {
 	if input.Username == "" || input.Email == "" || input.Password == "" {
 		return nil, errors.New("username, email, and password are required")
 	}
 
-	var count int64
-	s.DB.Model(&User{}).Where("email = ? OR username = ?", input.Email, input.Username).Count(&count)
-	if count > 0 {
-		return nil, errors.New("email/username already used")
+	username, err := normalizeUsername(input.Username)
+	if err != nil {
+		return nil, err
+	}
+	email, err := normalizeEmail(input.Email)
+	if err != nil {
+		return nil, err
+	}
+	if err := validatePassword(input.Password); err != nil {
+		return nil, err
 	}
 
 	hash, err := bcrypt.GenerateFromPassword([]byte(input.Password), bcrypt.DefaultCost)
@@ -58,37 +143,105 @@ func (s *AuthService) Register(input RegisterReq) (*User, error) {
 		return nil, err
 	}
 
-	u := &User{
-		Username: input.Username,
-		Email:    input.Email,
-		Password: string(hash),
-	}
+	u := &User{Password: string(hash)}
+	u.setUsername(username)
+	u.setEmail(email)
 
-	if err := s.DB.Create(u).Error; err != nil {
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := checkUsernameFree(tx, username, 0); err != nil {
+			return err
+		}
+		if err := checkEmailFree(tx, email, 0); err != nil {
+			return err
+		}
+		return tx.Create(u).Error
+	})
+	if err != nil {
 		return nil, err
 	}
+
+	if err := s.SendVerificationEmail(u); err != nil {
+		log.Printf("⚠️ failed to send verification email to user %d: %v", u.ID, err)
+	}
 	return u, nil
 }
 
-func (s *AuthService) Login(input LoginReq) (*TokenResp, error) {
+func (s *AuthService) Login(input LoginReq, client ClientInfo) (*LoginResult, error) {
 	var u User
-	if err := s.DB.Where("email = ?", input.Email).First(&u).Error; err != nil {
+	if err := s.DB.Scopes(WhereEmail(input.Email)).First(&u).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return nil, errors.New("invalid credentials")
+			return nil, ErrInvalidCredentials
 		}
 		return nil, err
 	}
 
 	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(input.Password)); err != nil {
-		return nil, errors.New("invalid credentials")
+		return nil, ErrInvalidCredentials
 	}
 
-	accessToken, err := s.GenerateJWT(u.ID)
+	return s.finishLogin(&u, input.DeviceName, client)
+}
+
+// finishLogin is the common end of every login method: it either starts a
+// session or, with two-factor authentication enabled, issues a challenge.
+func (s *AuthService) finishLogin(u *User, deviceName string, client ClientInfo) (*LoginResult, error) {
+	if u.TOTPEnabledAt != nil {
+		token, err := s.createMFAChallenge(u.ID, deviceName)
+		if err != nil {
+			return nil, err
+		}
+		return &LoginResult{MFAToken: token}, nil
+	}
+
+	if client.DeviceName == "" {
+		client.DeviceName = deviceName
+	}
+	if err := s.reactivate(u.ID); err != nil {
+		return nil, err
+	}
+	tok, err := s.StartSession(u.ID, client)
 	if err != nil {
 		return nil, err
 	}
+	return &LoginResult{Tokens: tok}, nil
+}
+
+// reactivate undoes a deactivation and cancels a pending deletion. It is
+// only called once the user has logged in explicitly, never on refresh.
+func (s *AuthService) reactivate(userID uint) error {
+	return s.DB.Model(&User{}).
+		Where("id = ? AND (deactivated_at IS NOT NULL OR deletion_scheduled_at IS NOT NULL)", userID).
+		Updates(map[string]interface{}{"deactivated_at": nil, "deletion_scheduled_at": nil}).Error
+}
 
-	refreshToken, err := s.GenerateRefreshToken(u.ID)
+// StartSession opens a new session for the user and issues its first token
+// pair.
+func (s *AuthService) StartSession(userID uint, client ClientInfo) (*TokenResp, error) {
+	familyID, err := randomHex(16)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sess := Session{
+		UserID:     userID,
+		FamilyID:   familyID,
+		DeviceName: client.DeviceName,
+		UserAgent:  client.UserAgent,
+		IP:         client.IP,
+		CreatedAt:  now,
+		LastUsedAt: now,
+	}
+	if err := s.DB.Create(&sess).Error; err != nil {
+		return nil, err
+	}
+
+	refreshToken, err := s.issueRefreshToken(userID, familyID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := s.GenerateJWT(userID, familyID)
 	if err != nil {
 		return nil, err
 	}
@@ -99,29 +252,49 @@ func (s *AuthService) Login(input LoginReq) (*TokenResp, error) {
 	}, nil
 }
 
-func (s *AuthService) GenerateJWT(userID uint) (string, error) {
-	claims := jwt.RegisteredClaims{
-		Subject:   strconv.FormatUint(uint64(userID), 10),
-		ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-		IssuedAt:  jwt.NewNumericDate(time.Now()),
-		Issuer:    "unbound",
+// CheckPassword is used to confirm sensitive account changes.
+func (s *AuthService) CheckPassword(userID uint, password string) error {
+	var u User
+	if err := s.DB.Select("id", "password").First(&u, userID).Error; err != nil {
+		return err
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(u.Password), []byte(password)); err != nil {
+		return ErrInvalidCredentials
+	}
+	return nil
+}
+
+func (s *AuthService) GenerateJWT(userID uint, sessionID string) (string, error) {
+	jti, err := randomHex(16)
+	if err != nil {
+		return "", err
+	}
+
+	claims := Claims{
+		SessionID: sessionID,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   strconv.FormatUint(uint64(userID), 10),
+			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(time.Now()),
+			Issuer:    "unbound",
+		},
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString(s.JWTSecret)
+	return s.Keys.Sign(claims)
 }
 
-func (s *AuthService) GenerateRefreshToken(userID uint) (string, error) {
-	b := make([]byte, 32)
-	if _, err := rand.Read(b); err != nil {
+func (s *AuthService) issueRefreshToken(userID uint, familyID string) (string, error) {
+	token, err := randomHex(32)
+	if err != nil {
 		return "", err
 	}
-	token := hex.EncodeToString(b)
 	expiry := time.Now().Add(7 * 24 * time.Hour)
 
 	rt := RefreshToken{
 		UserID:    userID,
-		Token:     token,
+		FamilyID:  familyID,
+		TokenHash: s.HashToken(token),
 		ExpiresAt: expiry,
 	}
 	if err := s.DB.Create(&rt).Error; err != nil {
@@ -130,32 +303,71 @@ func (s *AuthService) GenerateRefreshToken(userID uint) (string, error) {
 	return token, nil
 }
 
+func randomHex(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
 func (s *AuthService) ParseToken(tokenStr string) (uint, error) {
-	tok, err := jwt.ParseWithClaims(tokenStr, &jwt.RegisteredClaims{}, func(t *jwt.Token) (interface{}, error) {
-		return s.JWTSecret, nil
-	})
+	claims, err := s.ParseClaims(tokenStr)
 	if err != nil {
 		return 0, err
 	}
+	return claims.UserID()
+}
+
+func (s *AuthService) ParseClaims(tokenStr string) (*Claims, error) {
+	tok, err := s.Keys.Parse(tokenStr, &Claims{})
+	if err != nil {
+		return nil, err
+	}
 
-	if claims, ok := tok.Claims.(*jwt.RegisteredClaims); ok && tok.Valid {
-		id64, err := strconv.ParseUint(claims.Subject, 10, 64)
-		if err != nil {
-			return 0, err
-		}
-		return uint(id64), nil
+	claims, ok := tok.Claims.(*Claims)
+	if !ok || !tok.Valid {
+		return nil, errors.New("invalid token")
+	}
+	if s.Revocations.IsRevoked(claims) {
+		return nil, ErrTokenRevoked
 	}
-	return 0, errors.New("invalid token")
+	return claims, nil
+}
+
+func (c *Claims) UserID() (uint, error) {
+	id64, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint(id64), nil
 }
 
 func (s *AuthService) Logout(refreshToken string) error {
-	return s.DB.Where("token = ?", refreshToken).Delete(&RefreshToken{}).Error
+	var rt RefreshToken
+	if err := s.DB.Where("token_hash = ?", s.HashToken(refreshToken)).First(&rt).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+	return s.revokeFamily(rt)
 }
 
-func (s *AuthService) RefreshAccess(refreshToken string) (*TokenResp, error) {
+// RefreshAccess rotates the presented refresh token: the old token is marked
+// as rotated and a new one is issued in the same family. Presenting a token
+// that was already rotated means it leaked, so the whole family is revoked.
+func (s *AuthService) RefreshAccess(refreshToken string, client ClientInfo) (*TokenResp, error) {
 	var rt RefreshToken
-	if err := s.DB.Where("token = ?", refreshToken).First(&rt).Error; err != nil {
-		return nil, errors.New("invalid refresh token")
+	if err := s.DB.Where("token_hash = ?", s.HashToken(refreshToken)).First(&rt).Error; err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if rt.RotatedAt != nil {
+		if err := s.revokeFamily(rt); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
 	}
 
 	if time.Now().After(rt.ExpiresAt) {
@@ -163,13 +375,122 @@ func (s *AuthService) RefreshAccess(refreshToken string) (*TokenResp, error) {
 		return nil, errors.New("refresh token expired")
 	}
 
-	newAccess, err := s.GenerateJWT(rt.UserID)
+	res := s.DB.Model(&RefreshToken{}).
+		Where("id = ? AND rotated_at IS NULL", rt.ID).
+		Update("rotated_at", time.Now())
+	if res.Error != nil {
+		return nil, res.Error
+	}
+	if res.RowsAffected == 0 {
+		// Another request rotated this token between our read and update.
+		if err := s.revokeFamily(rt); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if rt.FamilyID == "" {
+		// Tokens issued before sessions existed get a session of their own.
+		return s.StartSession(rt.UserID, client)
+	}
+
+	s.DB.Model(&Session{}).Where("family_id = ?", rt.FamilyID).Updates(map[string]interface{}{
+		"last_used_at": time.Now(),
+		"user_agent":   client.UserAgent,
+		"ip":           client.IP,
+	})
+
+	newRefresh, err := s.issueRefreshToken(rt.UserID, rt.FamilyID)
+	if err != nil {
+		return nil, err
+	}
+
+	newAccess, err := s.GenerateJWT(rt.UserID, rt.FamilyID)
 	if err != nil {
 		return nil, err
 	}
 
 	return &TokenResp{
 		AccessToken:  newAccess,
-		RefreshToken: refreshToken,
+		RefreshToken: newRefresh,
 	}, nil
 }
+
+func (s *AuthService) revokeFamily(rt RefreshToken) error {
+	if rt.FamilyID == "" {
+		return s.DB.Delete(&RefreshToken{}, rt.ID).Error
+	}
+	return s.revokeFamilies(rt.UserID, []string{rt.FamilyID})
+}
+
+// revokeFamilies ends the given sessions: their refresh tokens are deleted
+// and access tokens already handed out for them are denylisted.
+func (s *AuthService) revokeFamilies(userID uint, familyIDs []string) error {
+	if len(familyIDs) == 0 {
+		return nil
+	}
+	err := s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("family_id IN ?", familyIDs).Delete(&RefreshToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("family_id IN ?", familyIDs).Delete(&Session{}).Error
+	})
+	if err != nil {
+		return err
+	}
+	return s.Revocations.RevokeSessions(familyIDs, userID)
+}
+
+func (s *AuthService) ListSessions(userID uint) ([]Session, error) {
+	var sessions []Session
+	err := s.DB.Where("user_id = ?", userID).Order("last_used_at DESC").Find(&sessions).Error
+	return sessions, err
+}
+
+func (s *AuthService) RevokeSession(userID, sessionID uint) error {
+	var sess Session
+	if err := s.DB.Where("id = ? AND user_id = ?", sessionID, userID).First(&sess).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrSessionNotFound
+		}
+		return err
+	}
+	return s.revokeFamilies(userID, []string{sess.FamilyID})
+}
+
+// RevokeOtherSessions logs the user out of every session except the one
+// identified by currentFamilyID.
+func (s *AuthService) RevokeOtherSessions(userID uint, currentFamilyID string) error {
+	var families []string
+	if err := s.DB.Model(&Session{}).
+		Where("user_id = ? AND family_id <> ?", userID, currentFamilyID).
+		Pluck("family_id", &families).Error; err != nil {
+		return err
+	}
+	return s.revokeFamilies(userID, families)
+}
+
+// RevokeAllSessions logs the user out everywhere: every session and personal
+// access token is ended and any access token issued up to now stops working
+// immediately.
+func (s *AuthService) RevokeAllSessions(userID uint) error {
+	if err := s.Revocations.RevokeUserBefore(userID, time.Now()); err != nil {
+		return err
+	}
+	if err := s.DB.Where("user_id = ?", userID).Delete(&PersonalAccessToken{}).Error; err != nil {
+		return err
+	}
+	if err := s.DB.Where("user_id = ?", userID).Delete(&RefreshToken{}).Error; err != nil {
+		return err
+	}
+	return s.DB.Where("user_id = ?", userID).Delete(&Session{}).Error
+}
+
+// RevokeAccessToken denylists a single access token, e.g. on logout.
+func (s *AuthService) RevokeAccessToken(c *Claims) error {
+	uid, err := c.UserID()
+	if err != nil {
+		return err
+	}
+	return s.Revocations.RevokeToken(c.ID, uid)
+}
@@ -0,0 +1,96 @@
+package auth
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testAuthService runs against the database in TEST_DATABASE_URL inside a
+// transaction that is rolled back afterwards.
+func testAuthService(t *testing.T) *AuthService {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := db.Begin()
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+	if err := tx.AutoMigrate(&User{}, &Session{}, &RefreshToken{}, &RevokedToken{},
+		&TokenCutoff{}, &PersonalAccessToken{}, &Role{}, &EmailVerificationToken{}, &PasswordResetToken{}); err != nil {
+		t.Fatal(err)
+	}
+
+	keys, err := ephemeralKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &AuthService{
+		DB:       tx,
+		Keys:     keys,
+		TokenKey: []byte("test-token-key"),
+		Revocations: &RevocationStore{
+			db:      tx,
+			keys:    make(map[string]time.Time),
+			cutoffs: make(map[uint]time.Time),
+		},
+		Guard: NewBruteForceGuard(tx),
+	}
+}
+
+func createTestUser(t *testing.T, s *AuthService) *User {
+	t.Helper()
+	suffix, err := randomHex(4)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u := &User{Password: "unused"}
+	u.setUsername("tester" + suffix)
+	u.setEmail("tester" + suffix + "@example.com")
+	if err := s.DB.Create(u).Error; err != nil {
+		t.Fatal(err)
+	}
+	return u
+}
+
+func TestRefreshRotation(t *testing.T) {
+	s := testAuthService(t)
+	u := createTestUser(t, s)
+
+	first, err := s.StartSession(u.ID, ClientInfo{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	second, err := s.RefreshAccess(first.RefreshToken, ClientInfo{})
+	if err != nil {
+		t.Fatalf("RefreshAccess: %v", err)
+	}
+	if second.RefreshToken == first.RefreshToken {
+		t.Fatal("refresh token was not rotated")
+	}
+	if _, err := s.ParseClaims(second.AccessToken); err != nil {
+		t.Fatalf("new access token: %v", err)
+	}
+
+	// Replaying a rotated token ends the whole session.
+	if _, err := s.RefreshAccess(first.RefreshToken, ClientInfo{}); err != ErrRefreshTokenReused {
+		t.Fatalf("reused refresh token: err = %v, want ErrRefreshTokenReused", err)
+	}
+	if _, err := s.RefreshAccess(second.RefreshToken, ClientInfo{}); err != ErrInvalidRefreshToken {
+		t.Errorf("refresh after reuse: err = %v, want ErrInvalidRefreshToken", err)
+	}
+	if _, err := s.ParseClaims(second.AccessToken); err != ErrTokenRevoked {
+		t.Errorf("access token after reuse: err = %v, want ErrTokenRevoked", err)
+	}
+}
@@ -0,0 +1,22 @@
+package auth
+
+import "time"
+
+// Session is one logged-in device. It lives as long as its refresh token
+// family and is removed together with it.
+type Session struct {
+	ID         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"index;not null" json:"-"`
+	FamilyID   string    `gorm:"uniqueIndex;size:64;not null" json:"-"`
+	DeviceName string    `json:"device_name"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastUsedAt time.Time `json:"last_used_at"`
+}
+
+type ClientInfo struct {
+	DeviceName string
+	UserAgent  string
+	IP         string
+}
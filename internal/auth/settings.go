@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+	"unbound/internal/common/mailer"
+)
+
+const usernameChangeCooldown = 30 * 24 * time.Hour
+
+var (
+	ErrUnchanged             = errors.New("new value is the same as the current one")
+	ErrUsernameChangeTooSoon = errors.New("username can only be changed once every 30 days")
+)
+
+// ChangePassword sets a new password after confirming the current one. Every
+// session except the caller's is logged out and personal access tokens are
+// revoked, since they may have been created by whoever knew the old password.
+func (s *AuthService) ChangePassword(userID uint, currentFamilyID, current, next string) error {
+	if err := s.CheckPassword(userID, current); err != nil {
+		return err
+	}
+	if err := validatePassword(next); err != nil {
+		return err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(next), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	if err := s.DB.Model(&User{}).Where("id = ?", userID).Update("password", string(hash)).Error; err != nil {
+		return err
+	}
+	if err := s.DB.Where("user_id = ? AND used_at IS NULL", userID).Delete(&PasswordResetToken{}).Error; err != nil {
+		return err
+	}
+
+	if err := s.DB.Where("user_id = ?", userID).Delete(&PersonalAccessToken{}).Error; err != nil {
+		return err
+	}
+	return s.RevokeOtherSessions(userID, currentFamilyID)
+}
+
+// ChangeEmail asks to move the account to a new address. The address is kept
+// as pending and a verification link is mailed to it; the account switches
+// over only when VerifyEmail gets that link. The old address is told about
+// the request so its owner can react if it was not them.
+func (s *AuthService) ChangeEmail(userID uint, password, email string) error {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return err
+	}
+	if err := s.CheckPassword(userID, password); err != nil {
+		return err
+	}
+
+	var u User
+	if err := s.DB.First(&u, userID).Error; err != nil {
+		return err
+	}
+	if u.Email == email {
+		return ErrUnchanged
+	}
+	if err := checkEmailFree(s.DB, email, userID); err != nil {
+		return err
+	}
+	if err := s.DB.Model(&User{}).Where("id = ?", userID).Update("pending_email", email).Error; err != nil {
+		return err
+	}
+
+	u.PendingEmail = &email
+	if err := s.SendVerificationEmail(&u); err != nil {
+		return err
+	}
+
+	go func() {
+		err := s.Mailer.Send(mailer.Message{
+			To:      u.Email,
+			Subject: "Permintaan ganti email akun Unbound kamu",
+			Body: fmt.Sprintf("Halo %s,\n\nAda permintaan untuk mengganti email akun kamu ke %s. Email baru dipakai setelah diverifikasi lewat link yang dikirim ke alamat itu.\n\nKalau ini bukan kamu, segera reset password dan hubungi support.\n",
+				u.Username, email),
+		})
+		if err != nil {
+			log.Printf("⚠️ failed to send email change notice to user %d: %v", u.ID, err)
+		}
+	}()
+	return nil
+}
+
+// ChangeUsername renames the account, at most once per cooldown period. The
+// old name is kept in UsernameHistory so profile links keep resolving.
+func (s *AuthService) ChangeUsername(userID uint, username string) (*User, error) {
+	username, err := normalizeUsername(username)
+	if err != nil {
+		return nil, err
+	}
+
+	var u User
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.First(&u, userID).Error; err != nil {
+			return err
+		}
+		if u.Username == username {
+			return ErrUnchanged
+		}
+		if u.UsernameChangedAt != nil && time.Since(*u.UsernameChangedAt) < usernameChangeCooldown {
+			return ErrUsernameChangeTooSoon
+		}
+
+		if err := checkUsernameFree(tx, username, userID); err != nil {
+			return err
+		}
+
+		// Either name may still redirect to another account's profile; a
+		// live account always takes precedence over a redirect.
+		oldCanonical := u.UsernameCanonical
+		u.setUsername(username)
+		if err := tx.Where("old_username IN ?", []string{oldCanonical, u.UsernameCanonical}).
+			Delete(&UsernameHistory{}).Error; err != nil {
+			return err
+		}
+		if oldCanonical != u.UsernameCanonical {
+			if err := tx.Create(&UsernameHistory{UserID: userID, OldUsername: oldCanonical}).Error; err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		u.UsernameChangedAt = &now
+		return tx.Model(&User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"username":            u.Username,
+			"username_canonical":  u.UsernameCanonical,
+			"username_skeleton":   u.UsernameSkeleton,
+			"username_changed_at": now,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &u, nil
+}
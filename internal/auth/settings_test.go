@@ -0,0 +1,75 @@
+package auth
+
+import (
+	"regexp"
+	"sync"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+	"unbound/internal/common/mailer"
+)
+
+type memoryMailer struct {
+	mu   sync.Mutex
+	sent []mailer.Message
+}
+
+func (m *memoryMailer) Send(msg mailer.Message) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+var verificationLink = regexp.MustCompile(`verify-email\?token=([0-9a-f]+)`)
+
+// lastToken returns the verification token of the last mail sent to addr.
+func (m *memoryMailer) lastToken(addr string) string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for i := len(m.sent) - 1; i >= 0; i-- {
+		if m.sent[i].To != addr {
+			continue
+		}
+		if match := verificationLink.FindStringSubmatch(m.sent[i].Body); match != nil {
+			return match[1]
+		}
+	}
+	return ""
+}
+
+func TestChangeEmail(t *testing.T) {
+	s := testAuthService(t)
+	mail := &memoryMailer{}
+	s.Mailer = mail
+	u := createTestUser(t, s)
+	hash, err := bcrypt.GenerateFromPassword([]byte("old-password"), bcrypt.MinCost)
+	if err != nil {
+		t.Fatal(err)
+	}
+	must(t, s.DB.Model(u).Update("password", string(hash)).Error)
+
+	newEmail := "moved-" + u.Email
+	if err := s.ChangeEmail(u.ID, "old-password", newEmail); err != nil {
+		t.Fatalf("ChangeEmail: %v", err)
+	}
+
+	// The account keeps its address until the new one is verified.
+	var got User
+	must(t, s.DB.First(&got, u.ID).Error)
+	if got.Email != u.Email || got.PendingEmail == nil || *got.PendingEmail != newEmail {
+		t.Fatalf("after ChangeEmail: email %q pending %v, want %q pending %q", got.Email, got.PendingEmail, u.Email, newEmail)
+	}
+
+	token := mail.lastToken(newEmail)
+	if token == "" {
+		t.Fatal("no verification link was sent to the new address")
+	}
+	if err := s.VerifyEmail(token); err != nil {
+		t.Fatalf("VerifyEmail: %v", err)
+	}
+	must(t, s.DB.First(&got, u.ID).Error)
+	if got.Email != newEmail || got.PendingEmail != nil || got.EmailVerifiedAt == nil {
+		t.Errorf("after VerifyEmail: email %q pending %v verified %v, want %q verified", got.Email, got.PendingEmail, got.EmailVerifiedAt, newEmail)
+	}
+}
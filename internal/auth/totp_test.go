@@ -0,0 +1,64 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// rfc6238Secret is the SHA1 test key of RFC 6238, "12345678901234567890".
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestVerifyTOTPVectors(t *testing.T) {
+	// The RFC lists 8-digit codes; the 6-digit codes are their last digits.
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, tt := range tests {
+		step, ok := verifyTOTP(rfc6238Secret, tt.code, time.Unix(tt.unix, 0))
+		if !ok || step != tt.unix/totpPeriod {
+			t.Errorf("verifyTOTP(%q) at %d = %d, %v; want %d, true", tt.code, tt.unix, step, ok, tt.unix/totpPeriod)
+		}
+	}
+}
+
+func TestVerifyTOTPWindow(t *testing.T) {
+	key, err := b32.DecodeString(rfc6238Secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(1_700_000_000, 0)
+	current := now.Unix() / totpPeriod
+	codeAt := func(offset int64) string { return hotp(key, uint64(current+offset)) }
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", rfc6238Secret, codeAt(0), current, true},
+		{"previous step", rfc6238Secret, codeAt(-1), current - 1, true},
+		{"next step", rfc6238Secret, codeAt(1), current + 1, true},
+		{"two steps old", rfc6238Secret, codeAt(-2), 0, false},
+		{"two steps ahead", rfc6238Secret, codeAt(2), 0, false},
+		{"with spaces", rfc6238Secret, codeAt(0)[:3] + " " + codeAt(0)[3:], current, true},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", codeAt(0), current, true},
+		{"too short", rfc6238Secret, codeAt(0)[:5], 0, false},
+		{"too long", rfc6238Secret, codeAt(0) + "0", 0, false},
+		{"invalid secret", "not base32!", codeAt(0), 0, false},
+	}
+	for _, tt := range tests {
+		step, ok := verifyTOTP(tt.secret, tt.code, now)
+		if ok != tt.wantOK || step != tt.wantStep {
+			t.Errorf("%s: verifyTOTP = %d, %v; want %d, %v", tt.name, step, ok, tt.wantStep, tt.wantOK)
+		}
+	}
+}
@@ -0,0 +1,13 @@
+package auth
+
+import "time"
+
+// UsernameHistory remembers names a user has given up, in canonical form, so
+// links to the old profile URL can be redirected. A row is dropped once someone else claims
+// the name.
+type UsernameHistory struct {
+	ID          uint   `gorm:"primaryKey"`
+	UserID      uint   `gorm:"index;not null"`
+	OldUsername string `gorm:"uniqueIndex;not null"`
+	CreatedAt   time.Time
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"unbound/internal/common/mailer"
+)
+
+const (
+	verificationTTL      = 24 * time.Hour
+	verificationCooldown = time.Minute
+)
+
+var (
+	ErrInvalidVerificationToken = errors.New("invalid or expired verification token")
+	ErrEmailAlreadyVerified     = errors.New("email already verified")
+	ErrVerificationCooldown     = errors.New("please wait before requesting another verification email")
+)
+
+// SendVerificationEmail replaces any pending verification token of the user
+// with a new one and mails it to the address waiting for verification: the
+// pending one during an email change, otherwise the current one.
+func (s *AuthService) SendVerificationEmail(u *User) error {
+	token, err := randomHex(32)
+	if err != nil {
+		return err
+	}
+	email := u.Email
+	if u.PendingEmail != nil {
+		email = *u.PendingEmail
+	}
+
+	err = s.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", u.ID).Delete(&EmailVerificationToken{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&EmailVerificationToken{
+			UserID:    u.ID,
+			Email:     email,
+			TokenHash: s.HashToken(token),
+			ExpiresAt: time.Now().Add(verificationTTL),
+		}).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	return s.Mailer.Send(mailer.Message{
+		To:      email,
+		Subject: "Verifikasi email Unbound kamu",
+		Body: fmt.Sprintf("Halo %s,\n\nKlik link berikut untuk memverifikasi email kamu:\n%s/verify-email?token=%s\n\nAtau kirim token ini ke POST /auth/verify-email:\n%s\n\nLink berlaku 24 jam.\n",
+			u.Username, s.AppURL, token, token),
+	})
+}
+
+func (s *AuthService) ResendVerification(userID uint) error {
+	var u User
+	if err := s.DB.First(&u, userID).Error; err != nil {
+		return err
+	}
+	if u.EmailVerifiedAt != nil && u.PendingEmail == nil {
+		return ErrEmailAlreadyVerified
+	}
+
+	var last EmailVerificationToken
+	if err := s.DB.Where("user_id = ?", userID).Order("created_at DESC").
+		Limit(1).Find(&last).Error; err == nil && last.ID != 0 &&
+		time.Since(last.CreatedAt) < verificationCooldown {
+		return ErrVerificationCooldown
+	}
+
+	return s.SendVerificationEmail(&u)
+}
+
+// VerifyEmail marks the address of the token as verified. When it is the
+// pending address of an email change, the account switches over to it.
+func (s *AuthService) VerifyEmail(token string) error {
+	var vt EmailVerificationToken
+	if err := s.DB.Where("token_hash = ?", s.HashToken(token)).First(&vt).Error; err != nil {
+		return ErrInvalidVerificationToken
+	}
+	if time.Now().After(vt.ExpiresAt) {
+		s.DB.Delete(&vt)
+		return ErrInvalidVerificationToken
+	}
+
+	return s.DB.Transaction(func(tx *gorm.DB) error {
+		var u User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&u, vt.UserID).Error; err != nil {
+			return ErrInvalidVerificationToken
+		}
+
+		switch {
+		case u.Email == vt.Email:
+			if err := tx.Model(&u).Update("email_verified_at", time.Now()).Error; err != nil {
+				return err
+			}
+		case u.PendingEmail != nil && *u.PendingEmail == vt.Email:
+			// Someone may have taken the address since the change was asked for.
+			if err := checkEmailFree(tx, vt.Email, u.ID); err != nil {
+				return err
+			}
+			u.setEmail(vt.Email)
+			if err := tx.Model(&u).Updates(map[string]interface{}{
+				"email":             u.Email,
+				"email_canonical":   u.EmailCanonical,
+				"email_verified_at": time.Now(),
+				"pending_email":     nil,
+			}).Error; err != nil {
+				return err
+			}
+			// Reset links went to the old address.
+			if err := tx.Where("user_id = ? AND used_at IS NULL", u.ID).Delete(&PasswordResetToken{}).Error; err != nil {
+				return err
+			}
+		default:
+			return ErrInvalidVerificationToken
+		}
+		return tx.Where("user_id = ?", vt.UserID).Delete(&EmailVerificationToken{}).Error
+	})
+}
+
+func (s *AuthService) IsEmailVerified(userID uint) (bool, error) {
+	var u User
+	if err := s.DB.Select("id", "email_verified_at").First(&u, userID).Error; err != nil {
+		return false, err
+	}
+	return u.EmailVerifiedAt != nil, nil
+}
+
+// MarkExistingUsersVerified treats accounts created before email verification
+// existed as verified. It must run before AutoMigrate adds the column.
+func MarkExistingUsersVerified(db *gorm.DB) error {
+	m := db.Migrator()
+	if !m.HasTable(&User{}) || m.HasColumn(&User{}, "email_verified_at") {
+		return nil
+	}
+	if err := m.AddColumn(&User{}, "EmailVerifiedAt"); err != nil {
+		return err
+	}
+	res := db.Exec(`UPDATE users SET email_verified_at = created_at`)
+	if res.Error == nil {
+		log.Printf("✅ Marked %d existing users as email-verified", res.RowsAffected)
+	}
+	return res.Error
+}
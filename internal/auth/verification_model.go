@@ -0,0 +1,14 @@
+package auth
+
+import "time"
+
+// EmailVerificationToken proves ownership of Email. The address is stored so
+// a token cannot verify an email the user has since changed away from.
+type EmailVerificationToken struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index;not null"`
+	Email     string `gorm:"not null"`
+	TokenHash string `gorm:"uniqueIndex;size:64;not null"`
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
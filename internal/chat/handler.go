@@ -1,11 +1,13 @@
 package chat
 
 import (
+	"errors"
 	"fmt"
 	"strconv"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
 )
 
 type ChatHandler struct {
@@ -17,6 +19,16 @@ func NewChatHandler(s *ChatService, hub *WebSocketHub) *ChatHandler {
 	return &ChatHandler{Service: s, Hub: hub}
 }
 
+func chatError(err error) error {
+	switch {
+	case errors.Is(err, ErrBlocked), errors.Is(err, ErrNotChatMember):
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		return fiber.NewError(fiber.StatusNotFound, "chat not found")
+	}
+	return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+}
+
 func (h *ChatHandler) GetOrCreateChat(c *fiber.Ctx) error {
 	var userID uint
 	if v := c.Locals("userID"); v != nil {
@@ -34,7 +46,7 @@ func (h *ChatHandler) GetOrCreateChat(c *fiber.Ctx) error {
 
 	chat, err := h.Service.GetOrCreateChat(userID, uint(targetID))
 	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		return chatError(err)
 	}
 
 	fmt.Printf("[CHAT] User %d open chat with %d -> chat_id=%d\n", userID, targetID, chat.ID)
@@ -80,7 +92,7 @@ func (h *ChatHandler) SendMessage(c *fiber.Ctx) error {
 
 	msg, err := h.Service.SendMessage(uint(chatID), userID, req.Content)
 	if err != nil {
-		return fiber.NewError(fiber.StatusInternalServerError, err.Error())
+		return chatError(err)
 	}
 
 	fmt.Printf("[MESSAGE] User %d -> chat %d: %s\n", userID, chatID, req.Content)
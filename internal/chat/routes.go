@@ -1,7 +1,6 @@
 package chat
 
 import (
-	"os"
 	"strconv"
 
 	"github.com/gofiber/contrib/websocket"
@@ -19,9 +18,12 @@ func RegisterChatRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService)
 
 	h := NewChatHandler(svc, hub)
 
-	r := app.Group("/chats", middleware.JWTProtected(authSvc))
+	r := app.Group("/chats", middleware.JWTProtected(authSvc), middleware.RequireVerifiedEmail(authSvc))
 
-	r.Get("/", func(c *fiber.Ctx) error {
+	read := middleware.RequireScope(auth.ScopeChatsRead)
+	write := middleware.RequireScope(auth.ScopeChatsWrite)
+
+	r.Get("/", read, func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(uint)
 		var chats []Chat
 
@@ -37,13 +39,14 @@ func RegisterChatRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService)
 		return c.JSON(chats)
 	})
 
-	r.Post("/:user_id", h.GetOrCreateChat)
-	r.Get("/:chat_id/messages", h.GetMessages)
-	r.Post("/:chat_id/messages", h.SendMessage)
-	r.Put("/:chat_id/read", h.MarkAsRead)
+	r.Post("/:user_id", write, h.GetOrCreateChat)
+	r.Get("/:chat_id/messages", read, h.GetMessages)
+	r.Post("/:chat_id/messages", write, h.SendMessage)
+	r.Put("/:chat_id/read", write, h.MarkAsRead)
 
 	app.Get("/ws/chat/:chat_id",
-		middleware.WebSocketAuth(os.Getenv("JWT_SECRET")),
+		middleware.WebSocketAuth(authSvc),
+		middleware.RequireVerifiedEmail(authSvc),
 		websocket.New(func(c *websocket.Conn) {
 			chatID, _ := strconv.Atoi(c.Params("chat_id"))
 			c.Locals("chat_id", uint(chatID))
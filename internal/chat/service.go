@@ -1,12 +1,19 @@
 package chat
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"time"
 
 	"gorm.io/gorm"
 	"unbound/internal/notification"
+	"unbound/internal/user"
+)
+
+var (
+	ErrBlocked       = errors.New("you can't message this user")
+	ErrNotChatMember = errors.New("you are not part of this chat")
 )
 
 type ChatService struct {
@@ -18,8 +25,16 @@ func NewChatService(db *gorm.DB) *ChatService {
 }
 
 func (s *ChatService) GetOrCreateChat(user1ID, user2ID uint) (*Chat, error) {
+	blocked, err := user.IsBlocked(s.DB, user1ID, user2ID)
+	if err != nil {
+		return nil, err
+	}
+	if blocked {
+		return nil, ErrBlocked
+	}
+
 	var chat Chat
-	err := s.DB.
+	err = s.DB.
 		Where("(user1_id = ? AND user2_id = ?) OR (user1_id = ? AND user2_id = ?)",
 			user1ID, user2ID, user2ID, user1ID).
 		Preload("Messages").
@@ -45,7 +60,36 @@ func (s *ChatService) GetMessages(chatID uint) ([]Message, error) {
 	return messages, err
 }
 
+// checkCanSend makes sure the sender belongs to the chat and that neither
+// side blocked the other, and returns the other participant.
+func (s *ChatService) checkCanSend(chatID, senderID uint) (uint, error) {
+	var chat Chat
+	if err := s.DB.First(&chat, chatID).Error; err != nil {
+		return 0, err
+	}
+	receiverID := chat.User1ID
+	if receiverID == senderID {
+		receiverID = chat.User2ID
+	} else if chat.User2ID != senderID {
+		return 0, ErrNotChatMember
+	}
+
+	blocked, err := user.IsBlocked(s.DB, senderID, receiverID)
+	if err != nil {
+		return 0, err
+	}
+	if blocked {
+		return 0, ErrBlocked
+	}
+	return receiverID, nil
+}
+
 func (s *ChatService) SendMessage(chatID, senderID uint, content string) (*Message, error) {
+	targetUser, err := s.checkCanSend(chatID, senderID)
+	if err != nil {
+		return nil, err
+	}
+
 	msg := Message{
 		ChatID:   chatID,
 		SenderID: senderID,
@@ -58,26 +102,16 @@ func (s *ChatService) SendMessage(chatID, senderID uint, content string) (*Messa
 		return nil, err
 	}
 
-	var chat Chat
-	if err := s.DB.First(&chat, chatID).Error; err == nil {
-		var targetUser uint
-		if chat.User1ID == senderID {
-			targetUser = chat.User2ID
-		} else {
-			targetUser = chat.User1ID
-		}
-
-		notif := notification.Notification{
-			UserID:  targetUser,
-			ActorID: senderID,
-			Type:    "message",
-			Message: fmt.Sprintf("Pesan baru dari user %d", senderID),
-			IsRead:  false,
-		}
+	notif := notification.Notification{
+		UserID:  targetUser,
+		ActorID: senderID,
+		Type:    "message",
+		Message: fmt.Sprintf("Pesan baru dari user %d", senderID),
+		IsRead:  false,
+	}
 
-		if err := s.DB.Create(&notif).Error; err != nil {
-			log.Printf("⚠️ gagal bikin notif: %v", err)
-		}
+	if err := s.DB.Create(&notif).Error; err != nil {
+		log.Printf("⚠️ gagal bikin notif: %v", err)
 	}
 
 	return &msg, nil
@@ -95,8 +129,8 @@ func (s *ChatService) MarkMessagesAsRead(chatID, userID uint, t time.Time) error
 		Model(&Message{}).
 		Where("chat_id = ? AND sender_id != ? AND status != ?", chatID, userID, "read").
 		Updates(map[string]interface{}{
-			"status":   "read",
-			"read_at":  t,
-			"is_read":  true,
+			"status":  "read",
+			"read_at": t,
+			"is_read": true,
 		}).Error
 }
@@ -64,6 +64,7 @@ func (h *WebSocketHub) HandleWebSocket(c *websocket.Conn) {
 	h.Mutex.Unlock()
 
 	log.Printf("User %d connected to chat %d", userID, chatID)
+	svc := NewChatService(h.DB)
 
 	if err := h.DB.Model(&Message{}).
 		Where("chat_id = ? AND sender_id != ? AND status = ?", chatID, userID, "sent").
@@ -98,6 +99,11 @@ func (h *WebSocketHub) HandleWebSocket(c *websocket.Conn) {
 			continue
 		}
 
+		if _, err := svc.checkCanSend(chatID, userID); err != nil {
+			log.Printf("⚠️ user %d cannot send to chat %d: %v", userID, chatID, err)
+			continue
+		}
+
 		msg := Message{
 			ChatID:   chatID,
 			SenderID: userID,
@@ -8,6 +8,7 @@ import (
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 
+	"unbound/internal/account"
 	"unbound/internal/auth"
 	"unbound/internal/post"
 	"unbound/internal/user"
@@ -30,14 +31,51 @@ func Connect() *gorm.DB {
 		log.Fatalf("❌ Failed to connect to database: %v", err)
 	}
 
+	if err := auth.MigrateLegacyRefreshTokens(db); err != nil {
+		log.Fatalf("❌ Refresh token migration failed: %v", err)
+	}
+
+	if err := auth.MarkExistingUsersVerified(db); err != nil {
+		log.Fatalf("❌ Email verification migration failed: %v", err)
+	}
+
+	if err := auth.BackfillCanonicalIdentifiers(db); err != nil {
+		log.Fatalf("❌ Canonical username/email migration failed: %v", err)
+	}
+
+	if err := user.DedupeFollows(db); err != nil {
+		log.Fatalf("❌ Follow dedupe migration failed: %v", err)
+	}
+
 	err = db.AutoMigrate(
 		&auth.User{},
 		&post.Post{},
 		&post.Like{},
 		&post.Comment{},
 		&user.Follow{},
+		&user.Profile{},
+		&user.FollowRequest{},
+		&user.Block{},
+		&user.Mute{},
+		&user.SuggestionDismissal{},
+		&user.List{},
+		&user.ListMember{},
 		&auth.RefreshToken{},
+		&auth.Session{},
+		&auth.RevokedToken{},
+		&auth.TokenCutoff{},
+		&auth.EmailVerificationToken{},
+		&auth.PasswordResetToken{},
+		&auth.RecoveryCode{},
+		&auth.MFAChallenge{},
+		&auth.LoginAttempt{},
+		&auth.ExternalIdentity{},
+		&auth.OIDCAuthRequest{},
+		&auth.PersonalAccessToken{},
+		&auth.Role{},
+		&auth.UsernameHistory{},
 		&notification.Notification{},
+		&account.DataExport{},
 		&chat.Chat{},
 		&chat.Message{},
 	)
@@ -0,0 +1,143 @@
+// Package imaging decodes uploaded images and produces resized copies.
+// Every output is re-encoded from pixels, so EXIF, GPS and any other
+// metadata in the upload never reach storage.
+package imaging
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"image/draw"
+	_ "image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+// MaxPixels caps the decoded size so a small, highly compressed file
+// cannot exhaust memory.
+const MaxPixels = 25_000_000
+
+var (
+	ErrUnsupportedFormat = errors.New("image must be a JPEG, PNG or GIF")
+	ErrTooLarge          = errors.New("image dimensions are too large")
+	ErrInvalidImage      = errors.New("invalid image")
+)
+
+// Image is a decoded upload, already turned upright.
+type Image struct {
+	Pixels *image.RGBA
+	Format string
+}
+
+// Encoded is a ready-to-store file.
+type Encoded struct {
+	Data        []byte
+	ContentType string
+	Ext         string
+}
+
+// Decode checks the real type of data rather than trusting the client, then
+// decodes it and applies the EXIF orientation of JPEGs.
+func Decode(data []byte) (*Image, error) {
+	switch http.DetectContentType(data) {
+	case "image/jpeg", "image/png", "image/gif":
+	default:
+		return nil, ErrUnsupportedFormat
+	}
+
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return nil, ErrTooLarge
+	}
+
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrInvalidImage
+	}
+	rgba := image.NewRGBA(image.Rect(0, 0, src.Bounds().Dx(), src.Bounds().Dy()))
+	draw.Draw(rgba, rgba.Bounds(), src, src.Bounds().Min, draw.Src)
+
+	if format == "jpeg" {
+		rgba = orient(rgba, jpegOrientation(data))
+	}
+	return &Image{Pixels: rgba, Format: format}, nil
+}
+
+// Fill crops the image to the aspect ratio of w×h around its center and
+// scales it down to w×h. Smaller images are cropped but never enlarged.
+func (img *Image) Fill(w, h int) *image.RGBA {
+	b := img.Pixels.Bounds()
+	cw, ch := b.Dx(), b.Dy()
+	if cw*h > ch*w {
+		cw = ch * w / h
+	} else {
+		ch = cw * h / w
+	}
+	// Very thin images would round the crop down to nothing.
+	cw, ch = max(cw, 1), max(ch, 1)
+	x0, y0 := b.Min.X+(b.Dx()-cw)/2, b.Min.Y+(b.Dy()-ch)/2
+	crop := img.Pixels.SubImage(image.Rect(x0, y0, x0+cw, y0+ch)).(*image.RGBA)
+
+	if cw <= w {
+		w, h = cw, ch
+	}
+	return resize(crop, w, h)
+}
+
+// Encode stores images with transparency as PNG and everything else as
+// JPEG.
+func Encode(img *image.RGBA) (*Encoded, error) {
+	var buf bytes.Buffer
+	if !img.Opaque() {
+		if err := png.Encode(&buf, img); err != nil {
+			return nil, err
+		}
+		return &Encoded{Data: buf.Bytes(), ContentType: "image/png", Ext: "png"}, nil
+	}
+	if err := jpeg.Encode(&buf, img, &jpeg.Options{Quality: 85}); err != nil {
+		return nil, err
+	}
+	return &Encoded{Data: buf.Bytes(), ContentType: "image/jpeg", Ext: "jpg"}, nil
+}
+
+// resize scales src to w×h by averaging the source pixels that fall into
+// each destination pixel, which is what downscaling photos needs.
+func resize(src *image.RGBA, w, h int) *image.RGBA {
+	b := src.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		sy0, sy1 := y*sh/h, (y+1)*sh/h
+		if sy1 <= sy0 {
+			sy1 = sy0 + 1
+		}
+		for x := 0; x < w; x++ {
+			sx0, sx1 := x*sw/w, (x+1)*sw/w
+			if sx1 <= sx0 {
+				sx1 = sx0 + 1
+			}
+			var r, g, bl, a, n uint32
+			for sy := sy0; sy < sy1; sy++ {
+				i := src.PixOffset(b.Min.X+sx0, b.Min.Y+sy)
+				for sx := sx0; sx < sx1; sx++ {
+					r += uint32(src.Pix[i])
+					g += uint32(src.Pix[i+1])
+					bl += uint32(src.Pix[i+2])
+					a += uint32(src.Pix[i+3])
+					n++
+					i += 4
+				}
+			}
+			j := dst.PixOffset(x, y)
+			dst.Pix[j] = uint8(r / n)
+			dst.Pix[j+1] = uint8(g / n)
+			dst.Pix[j+2] = uint8(bl / n)
+			dst.Pix[j+3] = uint8(a / n)
+		}
+	}
+	return dst
+}
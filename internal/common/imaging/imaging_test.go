@@ -0,0 +1,34 @@
+package imaging
+
+import (
+	"image"
+	"testing"
+)
+
+func TestFill(t *testing.T) {
+	tests := []struct {
+		name         string
+		srcW, srcH   int
+		w, h         int
+		wantW, wantH int
+	}{
+		{"landscape to square", 800, 400, 200, 200, 200, 200},
+		{"portrait to banner", 600, 1200, 300, 100, 300, 100},
+		{"smaller than target is not enlarged", 100, 100, 400, 400, 100, 100},
+		{"very tall and thin", 1, 10000, 1500, 500, 1, 1},
+		{"very wide and flat", 10000, 1, 400, 400, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			img := &Image{Pixels: image.NewRGBA(image.Rect(0, 0, tt.srcW, tt.srcH))}
+			got := img.Fill(tt.w, tt.h).Bounds()
+			if got.Dx() != tt.wantW || got.Dy() != tt.wantH {
+				t.Fatalf("Fill(%d, %d) of %dx%d = %dx%d, want %dx%d",
+					tt.w, tt.h, tt.srcW, tt.srcH, got.Dx(), got.Dy(), tt.wantW, tt.wantH)
+			}
+			if _, err := Encode(img.Fill(tt.w, tt.h)); err != nil {
+				t.Fatalf("Encode: %v", err)
+			}
+		})
+	}
+}
@@ -7,18 +7,108 @@ import (
 	"unbound/internal/auth"
 )
 
+// JWTProtected accepts session access tokens and personal access tokens.
+// For the latter the granted scopes are stored in Locals("scopes") and
+// checked by RequireScope.
 func JWTProtected(authSvc *auth.AuthService) fiber.Handler {
+	return authenticate(authSvc, true)
+}
+
+// SessionProtected only accepts session access tokens, for endpoints that
+// manage the account itself.
+func SessionProtected(authSvc *auth.AuthService) fiber.Handler {
+	return authenticate(authSvc, false)
+}
+
+// OptionalAuth lets anonymous requests through but, when a token is sent,
+// validates it like JWTProtected so the handler knows who is asking. A
+// personal access token without posts:read is treated as anonymous, so it
+// cannot see more than a logged-out visitor.
+func OptionalAuth(authSvc *auth.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Get("Authorization") == "" {
+			return c.Next()
+		}
+		if err := identify(c, authSvc, true); err != nil {
+			return err
+		}
+		if scopes, isPAT := c.Locals("scopes").([]string); isPAT && !hasScope(scopes, auth.ScopePostsRead) {
+			c.Locals("userID", nil)
+			c.Locals("scopes", nil)
+		}
+		return c.Next()
+	}
+}
+
+func authenticate(authSvc *auth.AuthService, allowPAT bool) fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		h := c.Get("Authorization")
-		if h == "" || !strings.HasPrefix(h, "Bearer ") {
-			return fiber.NewError(fiber.StatusUnauthorized, "missing bearer token")
+		if err := identify(c, authSvc, allowPAT); err != nil {
+			return err
 		}
-		tokenStr := strings.TrimPrefix(h, "Bearer ")
-		uid, err := authSvc.ParseToken(tokenStr)
+		return c.Next()
+	}
+}
+
+// identify validates the bearer token and stores the caller in Locals.
+func identify(c *fiber.Ctx, authSvc *auth.AuthService, allowPAT bool) error {
+	h := c.Get("Authorization")
+	if h == "" || !strings.HasPrefix(h, "Bearer ") {
+		return fiber.NewError(fiber.StatusUnauthorized, "missing bearer token")
+	}
+	tokenStr := strings.TrimPrefix(h, "Bearer ")
+
+	if strings.HasPrefix(tokenStr, auth.PATPrefix) {
+		if !allowPAT {
+			return fiber.NewError(fiber.StatusForbidden, "personal access tokens cannot be used here")
+		}
+		pat, err := authSvc.AuthenticatePAT(tokenStr)
 		if err != nil {
 			return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
 		}
-		c.Locals("userID", uid)
-		return c.Next()
+		c.Locals("userID", pat.UserID)
+		c.Locals("scopes", pat.ScopeList())
+		return nil
+	}
+
+	claims, err := authSvc.ParseClaims(tokenStr)
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
+	}
+	uid, err := claims.UserID()
+	if err != nil {
+		return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
+	}
+	c.Locals("userID", uid)
+	c.Locals("sessionID", claims.SessionID)
+	return nil
+}
+
+// usesPAT tells from the request itself whether it carries a personal access
+// token, so scope checks fail closed even if no scopes were stored.
+func usesPAT(c *fiber.Ctx) bool {
+	return strings.HasPrefix(c.Get("Authorization"), "Bearer "+auth.PATPrefix)
+}
+
+func hasScope(scopes []string, scope string) bool {
+	for _, s := range scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireScope must run after JWTProtected. Session tokens carry every
+// scope; personal access tokens need the scope granted explicitly.
+func RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		scopes, ok := c.Locals("scopes").([]string)
+		if !ok && !usesPAT(c) {
+			return c.Next()
+		}
+		if hasScope(scopes, scope) {
+			return c.Next()
+		}
+		return fiber.NewError(fiber.StatusForbidden, "token is missing scope "+scope)
 	}
 }
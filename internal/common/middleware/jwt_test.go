@@ -0,0 +1,67 @@
+package middleware
+
+import (
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gofiber/fiber/v2"
+	"unbound/internal/auth"
+)
+
+func TestRequireScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		token  string
+		scopes []string
+		want   int
+	}{
+		{"session token", "eyJhbGciOi.session.token", nil, fiber.StatusOK},
+		{"PAT with the scope", auth.PATPrefix + "abc", []string{auth.ScopePostsRead, auth.ScopePostsWrite}, fiber.StatusOK},
+		{"PAT without the scope", auth.PATPrefix + "abc", []string{auth.ScopePostsRead}, fiber.StatusForbidden},
+		{"PAT without any scope", auth.PATPrefix + "abc", []string{}, fiber.StatusForbidden},
+		// Scopes missing from Locals must not turn a PAT into a session.
+		{"PAT with scopes lost", auth.PATPrefix + "abc", nil, fiber.StatusForbidden},
+	}
+	for _, tt := range tests {
+		app := fiber.New()
+		app.Get("/", func(c *fiber.Ctx) error {
+			c.Locals("userID", uint(1))
+			if tt.scopes != nil {
+				c.Locals("scopes", tt.scopes)
+			}
+			return c.Next()
+		}, RequireScope(auth.ScopePostsWrite), func(c *fiber.Ctx) error {
+			return c.SendStatus(fiber.StatusOK)
+		})
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Authorization", "Bearer "+tt.token)
+		resp, err := app.Test(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if resp.StatusCode != tt.want {
+			t.Errorf("%s: status = %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+}
+
+func TestRequirePermissionRejectsPAT(t *testing.T) {
+	app := fiber.New()
+	app.Get("/", func(c *fiber.Ctx) error {
+		c.Locals("userID", uint(1))
+		return c.Next()
+	}, RequirePermission(nil, auth.PermAll), func(c *fiber.Ctx) error {
+		return c.SendStatus(fiber.StatusOK)
+	})
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Authorization", "Bearer "+auth.PATPrefix+"abc")
+	resp, err := app.Test(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusForbidden {
+		t.Errorf("status = %d, want %d", resp.StatusCode, fiber.StatusForbidden)
+	}
+}
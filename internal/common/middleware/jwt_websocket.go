@@ -1,35 +1,23 @@
 package middleware
 
 import (
-	"fmt"
 	"github.com/gofiber/fiber/v2"
-	"github.com/golang-jwt/jwt/v5"
+	"unbound/internal/auth"
 )
 
-func WebSocketAuth(secret string) fiber.Handler {
+func WebSocketAuth(authSvc *auth.AuthService) fiber.Handler {
 	return func(c *fiber.Ctx) error {
 		tokenStr := c.Query("token")
 		if tokenStr == "" {
 			return fiber.NewError(fiber.StatusUnauthorized, "missing token")
 		}
 
-		token, err := jwt.Parse(tokenStr, func(token *jwt.Token) (interface{}, error) {
-			return []byte(secret), nil
-		})
-
-		if err != nil || !token.Valid {
+		claims, err := authSvc.ParseClaims(tokenStr)
+		if err != nil {
 			return fiber.NewError(fiber.StatusUnauthorized, "invalid token")
 		}
 
-		claims := token.Claims.(jwt.MapClaims)
-
-		sub, ok := claims["sub"].(string)
-		if !ok {
-			return fiber.NewError(fiber.StatusUnauthorized, "invalid subject claim")
-		}
-
-		var userID uint
-		_, err = fmt.Sscan(sub, &userID)
+		userID, err := claims.UserID()
 		if err != nil {
 			return fiber.NewError(fiber.StatusUnauthorized, "invalid user id in token")
 		}
@@ -0,0 +1,29 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"unbound/internal/auth"
+)
+
+// RequirePermission must run after JWTProtected. Personal access tokens are
+// never allowed to use role permissions.
+func RequirePermission(authSvc *auth.AuthService, perm string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if _, isPAT := c.Locals("scopes").([]string); isPAT || usesPAT(c) {
+			return fiber.NewError(fiber.StatusForbidden, "personal access tokens cannot be used here")
+		}
+		userID, ok := c.Locals("userID").(uint)
+		if !ok {
+			return fiber.NewError(fiber.StatusUnauthorized, "invalid user context")
+		}
+
+		allowed, err := authSvc.HasPermission(userID, perm)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to check permissions")
+		}
+		if !allowed {
+			return fiber.NewError(fiber.StatusForbidden, "missing permission "+perm)
+		}
+		return c.Next()
+	}
+}
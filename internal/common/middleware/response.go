@@ -4,7 +4,7 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
-func JSONResponseMiddleware(c *fiber.Ctx) error {u
+func JSONResponseMiddleware(c *fiber.Ctx) error {
 	err := c.Next()
 
 	if err != nil {
@@ -27,6 +27,11 @@ func JSONResponseMiddleware(c *fiber.Ctx) error {u
 		return nil
 	}
 
+	// File downloads are passed through untouched.
+	if len(c.Response().Header.Peek(fiber.HeaderContentDisposition)) > 0 {
+		return nil
+	}
+
 	return c.JSON(fiber.Map{
 		"success": true,
 		"data":    string(c.Response().Body()),
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"unbound/internal/auth"
+)
+
+// RequireVerifiedEmail must run after JWTProtected or WebSocketAuth.
+func RequireVerifiedEmail(authSvc *auth.AuthService) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		userID, ok := c.Locals("userID").(uint)
+		if !ok {
+			userID, ok = c.Locals("user_id").(uint)
+		}
+		if !ok {
+			return fiber.NewError(fiber.StatusUnauthorized, "invalid user context")
+		}
+
+		verified, err := authSvc.IsEmailVerified(userID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to check account status")
+		}
+		if !verified {
+			return fiber.NewError(fiber.StatusForbidden, "please verify your email first")
+		}
+		return c.Next()
+	}
+}
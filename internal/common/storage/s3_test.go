@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+)
+
+const (
+	testAccessKey = "test"
+	testSecretKey = "test-secret"
+)
+
+type mockObject struct {
+	header http.Header
+	data   []byte
+}
+
+// mockS3 is an in-memory bucket server that rejects every request without
+// a valid SigV4 signature, like cmd/mocks3 does for private buckets.
+type mockS3 struct {
+	mu      sync.Mutex
+	objects map[string]mockObject
+}
+
+func newMockS3(t *testing.T) (*mockS3, *httptest.Server) {
+	m := &mockS3{objects: make(map[string]mockObject)}
+	srv := httptest.NewServer(m)
+	t.Cleanup(srv.Close)
+	return m, srv
+}
+
+func (m *mockS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+	if !verifySigV4(r, body, testAccessKey, testSecretKey) {
+		http.Error(w, "SignatureDoesNotMatch", http.StatusForbidden)
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		m.objects[r.URL.Path] = mockObject{header: r.Header.Clone(), data: body}
+	case http.MethodGet:
+		obj, ok := m.objects[r.URL.Path]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Write(obj.data)
+	case http.MethodDelete:
+		if _, ok := m.objects[r.URL.Path]; !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		delete(m.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
+
+func (m *mockS3) object(path string) (mockObject, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.objects[path]
+	return obj, ok
+}
+
+// verifySigV4 recomputes the signature from what arrived over the wire.
+func verifySigV4(r *http.Request, body []byte, accessKey, secretKey string) bool {
+	var credential, signedHeaders, signature string
+	for _, part := range strings.Split(strings.TrimPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 "), ",") {
+		k, v, _ := strings.Cut(strings.TrimSpace(part), "=")
+		switch k {
+		case "Credential":
+			credential = v
+		case "SignedHeaders":
+			signedHeaders = v
+		case "Signature":
+			signature = v
+		}
+	}
+	key, scope, ok := strings.Cut(credential, "/")
+	if !ok || key != accessKey {
+		return false
+	}
+	fields := strings.Split(scope, "/")
+	if len(fields) != 4 || fields[2] != "s3" || fields[3] != "aws4_request" {
+		return false
+	}
+	payloadHash := r.Header.Get("X-Amz-Content-Sha256")
+	if payloadHash != sha256Hex(body) {
+		return false
+	}
+
+	var headers strings.Builder
+	for _, name := range strings.Split(signedHeaders, ";") {
+		value := r.Header.Get(name)
+		if name == "host" {
+			value = r.Host
+		}
+		headers.WriteString(name + ":" + strings.TrimSpace(value) + "\n")
+	}
+	canonical := strings.Join([]string{
+		r.Method, r.URL.EscapedPath(), r.URL.RawQuery, headers.String(), signedHeaders, payloadHash,
+	}, "\n")
+	toSign := "AWS4-HMAC-SHA256\n" + r.Header.Get("X-Amz-Date") + "\n" + scope + "\n" + sha256Hex([]byte(canonical))
+
+	signingKey := []byte("AWS4" + secretKey)
+	for _, f := range fields {
+		signingKey = hmacSHA256(signingKey, f)
+	}
+	return hmac.Equal([]byte(hex.EncodeToString(hmacSHA256(signingKey, toSign))), []byte(signature))
+}
+
+func newTestS3Store(endpoint string) *S3Store {
+	return &S3Store{
+		Endpoint:  endpoint,
+		Region:    "us-east-1",
+		Bucket:    "unbound",
+		AccessKey: testAccessKey,
+		SecretKey: testSecretKey,
+		PublicURL: "https://cdn.example.com",
+	}
+}
+
+func TestS3StorePutGetDelete(t *testing.T) {
+	mock, srv := newMockS3(t)
+	s := newTestS3Store(srv.URL)
+
+	key := "avatars/42/a b.jpg"
+	data := []byte("not really a jpeg")
+	if err := s.Put(key, "image/jpeg", data); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	obj, ok := mock.object("/unbound/avatars/42/a b.jpg")
+	if !ok {
+		t.Fatal("object was not stored under /bucket/key")
+	}
+	if got := obj.header.Get("Content-Type"); got != "image/jpeg" {
+		t.Errorf("Content-Type = %q, want image/jpeg", got)
+	}
+	if got := obj.header.Get("Cache-Control"); got != "public, max-age=31536000, immutable" {
+		t.Errorf("Cache-Control = %q", got)
+	}
+
+	got, err := s.Get(key)
+	if err != nil {
+		t.Fatalf("Get: %v", err)
+	}
+	if !bytes.Equal(got, data) {
+		t.Errorf("Get = %q, want %q", got, data)
+	}
+
+	if err := s.Delete(key); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	if _, ok := mock.object("/unbound/avatars/42/a b.jpg"); ok {
+		t.Error("object still stored after Delete")
+	}
+	if _, err := s.Get(key); err == nil {
+		t.Error("Get after Delete succeeded")
+	}
+	if err := s.Delete(key); err != nil {
+		t.Errorf("Delete of a missing key: %v", err)
+	}
+}
+
+func TestS3StorePrivateCacheControl(t *testing.T) {
+	mock, srv := newMockS3(t)
+	s := newTestS3Store(srv.URL)
+	s.Bucket = "unbound-private"
+	s.Private = true
+
+	if err := s.Put("exports/1.zip", "application/zip", []byte("zip")); err != nil {
+		t.Fatalf("Put: %v", err)
+	}
+	obj, _ := mock.object("/unbound-private/exports/1.zip")
+	if got := obj.header.Get("Cache-Control"); got != "private, no-store" {
+		t.Errorf("Cache-Control = %q, want private, no-store", got)
+	}
+}
+
+func TestS3StoreRejectedSignature(t *testing.T) {
+	mock, srv := newMockS3(t)
+	s := newTestS3Store(srv.URL)
+	s.SecretKey = "wrong"
+
+	if err := s.Put("avatars/1.jpg", "image/jpeg", []byte("x")); err == nil {
+		t.Fatal("Put with a wrong secret succeeded")
+	}
+	if _, ok := mock.object("/unbound/avatars/1.jpg"); ok {
+		t.Error("object stored despite the bad signature")
+	}
+	if _, err := s.Get("avatars/1.jpg"); err == nil {
+		t.Error("Get with a wrong secret succeeded")
+	}
+}
+
+func TestS3StoreURL(t *testing.T) {
+	s := newTestS3Store("http://s3.local")
+	if got, want := s.URL("avatars/42/a b.jpg"), "https://cdn.example.com/avatars/42/a%20b.jpg"; got != want {
+		t.Errorf("URL = %q, want %q", got, want)
+	}
+}
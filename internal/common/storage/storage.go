@@ -0,0 +1,142 @@
+package storage
+
+import (
+	"log"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// BlobStore keeps uploaded files. Keys are slash-separated paths chosen by
+// the caller; URL returns where clients can download the file.
+type BlobStore interface {
+	Put(key, contentType string, data []byte) error
+	Get(key string) ([]byte, error)
+	Delete(key string) error
+	URL(key string) string
+}
+
+// FromEnv picks the store from STORAGE_DRIVER. "s3" talks to any
+// S3-compatible service, anything else keeps files in MEDIA_DIR and serves
+// them from MEDIA_URL.
+func FromEnv() BlobStore {
+	if os.Getenv("STORAGE_DRIVER") == "s3" {
+		s := s3FromEnv(os.Getenv("S3_BUCKET"))
+		s.PublicURL = strings.TrimSuffix(os.Getenv("S3_PUBLIC_URL"), "/")
+		if s.PublicURL == "" {
+			s.PublicURL = s.Endpoint + "/" + s.Bucket
+		}
+		return s
+	}
+
+	dir := os.Getenv("MEDIA_DIR")
+	if dir == "" {
+		dir = "uploads"
+	}
+	baseURL := os.Getenv("MEDIA_URL")
+	if baseURL == "" {
+		appURL := os.Getenv("APP_URL")
+		if appURL == "" {
+			appURL = "http://localhost:8080"
+		}
+		baseURL = strings.TrimSuffix(appURL, "/") + "/media"
+	}
+	log.Printf("🗂️ Uploads are stored in %s (set STORAGE_DRIVER=s3 to use S3)", dir)
+	return &LocalStore{Dir: dir, BaseURL: strings.TrimSuffix(baseURL, "/")}
+}
+
+// PrivateFromEnv picks the store for files that must never be publicly
+// reachable, such as data exports. With STORAGE_DRIVER=s3 they go to
+// S3_PRIVATE_BUCKET, which must not allow public reads; otherwise they are
+// kept in PRIVATE_DIR, which the server does not serve. Such files are only
+// handed out by handlers that check access themselves.
+func PrivateFromEnv() BlobStore {
+	if os.Getenv("STORAGE_DRIVER") == "s3" {
+		bucket := os.Getenv("S3_PRIVATE_BUCKET")
+		if bucket == "" || bucket == os.Getenv("S3_BUCKET") {
+			log.Fatal("❌ S3_PRIVATE_BUCKET must be set to a private bucket other than S3_BUCKET")
+		}
+		s := s3FromEnv(bucket)
+		s.Private = true
+		return s
+	}
+
+	dir := os.Getenv("PRIVATE_DIR")
+	if dir == "" {
+		dir = "private-data"
+	}
+	return &LocalStore{Dir: dir}
+}
+
+func s3FromEnv(bucket string) *S3Store {
+	s := &S3Store{
+		Endpoint:  strings.TrimSuffix(os.Getenv("S3_ENDPOINT"), "/"),
+		Region:    os.Getenv("S3_REGION"),
+		Bucket:    bucket,
+		AccessKey: os.Getenv("S3_ACCESS_KEY"),
+		SecretKey: os.Getenv("S3_SECRET_KEY"),
+	}
+	if s.Region == "" {
+		s.Region = "us-east-1"
+	}
+	return s
+}
+
+// LocalStore writes files under Dir. The server mounts Dir at the path of
+// BaseURL, see Path.
+type LocalStore struct {
+	Dir     string
+	BaseURL string
+}
+
+func (s *LocalStore) Put(key, contentType string, data []byte) error {
+	p, err := s.file(key)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(p), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(p, data, 0o644)
+}
+
+func (s *LocalStore) Get(key string) ([]byte, error) {
+	p, err := s.file(key)
+	if err != nil {
+		return nil, err
+	}
+	return os.ReadFile(p)
+}
+
+func (s *LocalStore) Delete(key string) error {
+	p, err := s.file(key)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(p); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (s *LocalStore) URL(key string) string {
+	return s.BaseURL + "/" + key
+}
+
+// Path is the URL path the files have to be served from.
+func (s *LocalStore) Path() string {
+	if u, err := url.Parse(s.BaseURL); err == nil && u.Path != "" {
+		return u.Path
+	}
+	return "/media"
+}
+
+func (s *LocalStore) file(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || clean != "/"+key {
+		return "", os.ErrInvalid
+	}
+	return filepath.Join(s.Dir, filepath.FromSlash(clean)), nil
+}
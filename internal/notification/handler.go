@@ -10,11 +10,16 @@ import (
 func RegisterRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService) {
 	r := app.Group("/notifications")
 
-	r.Get("/", middleware.JWTProtected(authSvc), func(c *fiber.Ctx) error {
+	r.Get("/", middleware.JWTProtected(authSvc), middleware.RequireScope(auth.ScopeNotificationsRead), func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(uint)
 
+		// Notifications caused by blocked or muted accounts are not shown.
 		var notifs []Notification
-		if err := db.Where("user_id = ?", userID).Order("created_at DESC").Find(&notifs).Error; err != nil {
+		if err := db.Where("user_id = ?", userID).
+			Where(`NOT EXISTS (SELECT 1 FROM blocks b WHERE (b.blocker_id = notifications.user_id AND b.blocked_id = notifications.actor_id)
+				OR (b.blocker_id = notifications.actor_id AND b.blocked_id = notifications.user_id))`).
+			Where(`NOT EXISTS (SELECT 1 FROM mutes m WHERE m.muter_id = notifications.user_id AND m.muted_id = notifications.actor_id)`).
+			Order("created_at DESC").Find(&notifs).Error; err != nil {
 			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch notifications")
 		}
 
@@ -24,7 +29,7 @@ func RegisterRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService) {
 		})
 	})
 
-	r.Post("/read", middleware.JWTProtected(authSvc), func(c *fiber.Ctx) error {
+	r.Post("/read", middleware.JWTProtected(authSvc), middleware.RequireScope(auth.ScopeNotificationsWrite), func(c *fiber.Ctx) error {
 		userID := c.Locals("userID").(uint)
 		if err := db.Model(&Notification{}).Where("user_id = ?", userID).Update("is_read", true).Error; err != nil {
 			return fiber.NewError(fiber.StatusInternalServerError, "failed to update notifications")
@@ -11,7 +11,7 @@ import (
 func RegisterCommentEditRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService) {
 	r := app.Group("/posts")
 
-	r.Put("/:post_id/comments/:id", middleware.JWTProtected(authSvc), func(c *fiber.Ctx) error {
+	r.Put("/:post_id/comments/:id", middleware.JWTProtected(authSvc), middleware.RequireScope(auth.ScopePostsWrite), func(c *fiber.Ctx) error {
 		commentID := c.Params("id")
 
 		var body struct {
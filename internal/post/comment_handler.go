@@ -9,12 +9,13 @@ import (
 	"unbound/internal/common/middleware"
 	"unbound/internal/common/utils"
 	"unbound/internal/notification"
+	"unbound/internal/user"
 )
 
 func RegisterCommentRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService) {
 	r := app.Group("/posts")
 
-	r.Post("/:id/comments", middleware.JWTProtected(authSvc), func(c *fiber.Ctx) error {
+	r.Post("/:id/comments", middleware.JWTProtected(authSvc), middleware.RequireScope(auth.ScopePostsWrite), func(c *fiber.Ctx) error {
 		postID := c.Params("id")
 		userID, ok := c.Locals("userID").(uint)
 		if !ok {
@@ -28,6 +29,10 @@ func RegisterCommentRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthServic
 			return fiber.NewError(fiber.StatusBadRequest, "content is required")
 		}
 
+		if _, err := findPostToInteract(db, postID, userID); err != nil {
+			return postLookupError(err)
+		}
+
 		comment := Comment{
 			UserID:  userID,
 			PostID:  utils.ToUint(postID),
@@ -61,8 +66,11 @@ func RegisterCommentRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthServic
 		return c.Status(fiber.StatusCreated).JSON(comment)
 	})
 
-	r.Get("/:id/comments", func(c *fiber.Ctx) error {
+	r.Get("/:id/comments", middleware.OptionalAuth(authSvc), func(c *fiber.Ctx) error {
 		postID := c.Params("id")
+		if _, err := findVisiblePost(db, postID, user.ViewerID(c)); err != nil {
+			return postLookupError(err)
+		}
 		var comments []struct {
 			ID        uint   `json:"id"`
 			Username  string `json:"username"`
@@ -70,21 +78,22 @@ func RegisterCommentRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthServic
 			CreatedAt string `json:"created_at"`
 		}
 
+		hidden, args := user.HiddenSQL("c.user_id", user.ViewerID(c))
 		query := `
 			SELECT c.id, u.username, c.content, c.created_at
 			FROM comments c
 			JOIN users u ON u.id = c.user_id
-			WHERE c.post_id = ?
+			WHERE c.post_id = ? AND u.deactivated_at IS NULL AND NOT ` + hidden + `
 			ORDER BY c.created_at ASC
 		`
-		if err := db.Raw(query, postID).Scan(&comments).Error; err != nil {
+		if err := db.Raw(query, append([]interface{}{postID}, args...)...).Scan(&comments).Error; err != nil {
 			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch comments")
 		}
 
 		return c.JSON(comments)
 	})
 
-	r.Delete("/:post_id/comments/:id", middleware.JWTProtected(authSvc), func(c *fiber.Ctx) error {
+	r.Delete("/:post_id/comments/:id", middleware.JWTProtected(authSvc), middleware.RequireScope(auth.ScopePostsWrite), func(c *fiber.Ctx) error {
 		commentID := c.Params("id")
 		userID := c.Locals("userID").(uint)
 
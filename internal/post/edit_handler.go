@@ -10,7 +10,7 @@ import (
 func RegisterEditRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService) {
 	r := app.Group("/posts")
 
-	r.Put("/:id", middleware.JWTProtected(authSvc), func(c *fiber.Ctx) error {
+	r.Put("/:id", middleware.JWTProtected(authSvc), middleware.RequireScope(auth.ScopePostsWrite), func(c *fiber.Ctx) error {
 		postID := c.Params("id")
 		var body struct {
 			Content string `json:"content"`
@@ -1,12 +1,14 @@
 package post
 
 import (
+	"errors"
 	"strconv"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 	"unbound/internal/auth"
 	"unbound/internal/common/middleware"
+	"unbound/internal/user"
 )
 
 type FeedItem struct {
@@ -17,101 +19,93 @@ type FeedItem struct {
 	Likes     int64  `json:"likes"`
 }
 
+// feed pages through the posts matching filter, a raw condition on the
+// posts p and users u, using the limit, offset and sort query params.
+func feed(c *fiber.Ctx, db *gorm.DB, filter string, args []interface{}, errMsg string) error {
+	var results []FeedItem
+
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	offset, _ := strconv.Atoi(c.Query("offset", "0"))
+	sortOrder := c.Query("sort", "newest")
+
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+
+	order := "DESC"
+	if sortOrder == "oldest" {
+		order = "ASC"
+	}
+
+	query := `
+		SELECT p.id, u.username, p.content, p.created_at,
+			COUNT(DISTINCT l.id) AS likes
+		FROM posts p
+		JOIN users u ON u.id = p.user_id
+		LEFT JOIN likes l ON l.post_id = p.id
+		WHERE u.deactivated_at IS NULL AND ` + filter + `
+		GROUP BY p.id, u.username, p.content, p.created_at
+		ORDER BY p.created_at ` + order + `
+		LIMIT ? OFFSET ?
+	`
+
+	if err := db.Raw(query, append(args, limit, offset)...).Scan(&results).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, errMsg)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    results,
+		"meta": fiber.Map{
+			"limit":  limit,
+			"offset": offset,
+			"sort":   sortOrder,
+			"count":  len(results),
+		},
+	})
+}
+
 func RegisterFeedRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService) {
 	r := app.Group("/feed")
 
-	r.Get("/", func(c *fiber.Ctx) error {
-		var results []FeedItem
-
-		limit, _ := strconv.Atoi(c.Query("limit", "20"))
-		offset, _ := strconv.Atoi(c.Query("offset", "0"))
-		sortOrder := c.Query("sort", "newest")
-
-		if limit <= 0 || limit > 100 {
-			limit = 20
-		}
-
-		order := "DESC"
-		if sortOrder == "oldest" {
-			order = "ASC"
-		}
-
-		query := `
-			SELECT p.id, u.username, p.content, p.created_at,
-				COUNT(DISTINCT l.id) AS likes
-			FROM posts p
-			JOIN users u ON u.id = p.user_id
-			LEFT JOIN likes l ON l.post_id = p.id
-			GROUP BY p.id, u.username, p.content, p.created_at
-			ORDER BY p.created_at ` + order + `
-			LIMIT ? OFFSET ?
-		`
-
-		if err := db.Raw(query, limit, offset).Scan(&results).Error; err != nil {
-			return fiber.NewError(fiber.StatusInternalServerError, "failed to load feed")
-		}
-
-		return c.JSON(fiber.Map{
-			"success": true,
-			"data":    results,
-			"meta": fiber.Map{
-				"limit":  limit,
-				"offset": offset,
-				"sort":   sortOrder,
-				"count":  len(results),
-			},
-		})
+	r.Get("/", middleware.OptionalAuth(authSvc), func(c *fiber.Ctx) error {
+		visible, args := user.PostsVisibleSQL("p.user_id", user.ViewerID(c))
+		return feed(c, db, visible, args, "failed to load feed")
 	})
 
-	r.Get("/following", middleware.JWTProtected(authSvc), func(c *fiber.Ctx) error {
+	r.Get("/following", middleware.JWTProtected(authSvc), middleware.RequireScope(auth.ScopePostsRead), func(c *fiber.Ctx) error {
 		userID, ok := c.Locals("userID").(uint)
 		if !ok {
 			return fiber.NewError(fiber.StatusUnauthorized, "invalid user context")
 		}
 
-		var results []FeedItem
-
-		limit, _ := strconv.Atoi(c.Query("limit", "20"))
-		offset, _ := strconv.Atoi(c.Query("offset", "0"))
-		sortOrder := c.Query("sort", "newest")
+		hidden, hiddenArgs := user.HiddenSQL("p.user_id", userID)
+		filter := `(p.user_id IN (
+				SELECT following_id FROM follows WHERE follower_id = ? AND deleted_at IS NULL
+			)
+			OR p.user_id = ?)
+			AND NOT ` + hidden
 
-		if limit <= 0 || limit > 100 {
-			limit = 20
-		}
+		args := append([]interface{}{userID, userID}, hiddenArgs...)
+		return feed(c, db, filter, args, "failed to load following feed")
+	})
 
-		order := "DESC"
-		if sortOrder == "oldest" {
-			order = "ASC"
+	// A list timeline is the public feed narrowed down to the list members,
+	// so private accounts, blocks and mutes apply as usual.
+	app.Get("/lists/:id/timeline", middleware.OptionalAuth(authSvc), func(c *fiber.Ctx) error {
+		viewerID := user.ViewerID(c)
+		list, err := user.FindList(db, c.Params("id"), viewerID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fiber.NewError(fiber.StatusNotFound, "list not found")
+			}
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to load list timeline")
 		}
 
-		query := `
-			SELECT p.id, u.username, p.content, p.created_at,
-				COUNT(DISTINCT l.id) AS likes
-			FROM posts p
-			JOIN users u ON u.id = p.user_id
-			LEFT JOIN likes l ON l.post_id = p.id
-			WHERE p.user_id IN (
-				SELECT following_id FROM follows WHERE follower_id = ?
-			)
-			OR p.user_id = ?
-			GROUP BY p.id, u.username, p.content, p.created_at
-			ORDER BY p.created_at ` + order + `
-			LIMIT ? OFFSET ?
-		`
-
-		if err := db.Raw(query, userID, userID, limit, offset).Scan(&results).Error; err != nil {
-			return fiber.NewError(fiber.StatusInternalServerError, "failed to load following feed")
-		}
+		visible, visibleArgs := user.PostsVisibleSQL("p.user_id", viewerID)
+		filter := `p.user_id IN (SELECT user_id FROM list_members WHERE list_id = ?) AND ` + visible
 
-		return c.JSON(fiber.Map{
-			"success": true,
-			"data":    results,
-			"meta": fiber.Map{
-				"limit":  limit,
-				"offset": offset,
-				"sort":   sortOrder,
-				"count":  len(results),
-			},
-		})
+		args := append([]interface{}{list.ID}, visibleArgs...)
+		return feed(c, db, filter, args, "failed to load list timeline")
 	})
 }
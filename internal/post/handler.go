@@ -5,6 +5,7 @@ import (
 	"gorm.io/gorm"
 	"unbound/internal/auth"
 	"unbound/internal/common/middleware"
+	"unbound/internal/user"
 )
 
 type createPostReq struct {
@@ -14,15 +15,18 @@ type createPostReq struct {
 func RegisterRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService) {
 	r := app.Group("/posts")
 
-	r.Get("/", func(c *fiber.Ctx) error {
+	r.Get("/", middleware.OptionalAuth(authSvc), func(c *fiber.Ctx) error {
 		var posts []Post
-		if err := db.Order("id DESC").Limit(100).Find(&posts).Error; err != nil {
+		visible, args := user.PostsVisibleSQL("posts.user_id", user.ViewerID(c))
+		if err := db.Joins("JOIN users u ON u.id = posts.user_id").
+			Where("u.deactivated_at IS NULL AND "+visible, args...).
+			Order("posts.id DESC").Limit(100).Find(&posts).Error; err != nil {
 			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch posts")
 		}
 		return c.JSON(posts)
 	})
 
-	r.Post("/", middleware.JWTProtected(authSvc), func(c *fiber.Ctx) error {
+	r.Post("/", middleware.JWTProtected(authSvc), middleware.RequireScope(auth.ScopePostsWrite), func(c *fiber.Ctx) error {
 		var req createPostReq
 		if err := c.BodyParser(&req); err != nil || req.Content == "" {
 			return fiber.NewError(fiber.StatusBadRequest, "content is required")
@@ -42,7 +46,7 @@ func RegisterRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService) {
 		return c.Status(fiber.StatusCreated).JSON(p)
 	})
 
-	r.Delete("/:id", middleware.JWTProtected(authSvc), func(c *fiber.Ctx) error {
+	r.Delete("/:id", middleware.JWTProtected(authSvc), middleware.RequireScope(auth.ScopePostsWrite), func(c *fiber.Ctx) error {
 		postID := c.Params("id")
 		userID, ok := c.Locals("userID").(uint)
 		if !ok {
@@ -10,18 +10,23 @@ import (
 	"unbound/internal/common/middleware"
 	"unbound/internal/common/utils"
 	"unbound/internal/notification"
+	"unbound/internal/user"
 )
 
 func RegisterLikeRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService) {
 	r := app.Group("/posts")
 
-	r.Post("/:id/like", middleware.JWTProtected(authSvc), func(c *fiber.Ctx) error {
+	r.Post("/:id/like", middleware.JWTProtected(authSvc), middleware.RequireScope(auth.ScopePostsWrite), func(c *fiber.Ctx) error {
 		postID := c.Params("id")
 		userID, ok := c.Locals("userID").(uint)
 		if !ok {
 			return fiber.NewError(fiber.StatusUnauthorized, "invalid user context")
 		}
 
+		if _, err := findPostToInteract(db, postID, userID); err != nil {
+			return postLookupError(err)
+		}
+
 		var existing Like
 		if err := db.Where("user_id = ? AND post_id = ?", userID, postID).
 			Limit(1).Find(&existing).Error; err == nil && existing.ID != 0 {
@@ -68,8 +73,11 @@ func RegisterLikeRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService)
 		return c.JSON(fiber.Map{"liked": true})
 	})
 
-	r.Get("/:id/likes", func(c *fiber.Ctx) error {
+	r.Get("/:id/likes", middleware.OptionalAuth(authSvc), func(c *fiber.Ctx) error {
 		postID := c.Params("id")
+		if _, err := findVisiblePost(db, postID, user.ViewerID(c)); err != nil {
+			return postLookupError(err)
+		}
 		var count int64
 		if err := db.Model(&Like{}).Where("post_id = ?", postID).Count(&count).Error; err != nil {
 			return fiber.NewError(fiber.StatusInternalServerError, "failed to count likes")
@@ -0,0 +1,56 @@
+package post
+
+import (
+	"errors"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"unbound/internal/user"
+)
+
+var ErrBlocked = errors.New("you can't interact with this user's posts")
+
+// findVisiblePost loads the post if viewerID (0 for anonymous) may see it:
+// the author is active, and the post is not hidden by privacy, blocks or
+// mutes. Anything else is reported as gorm.ErrRecordNotFound.
+func findVisiblePost(db *gorm.DB, postID string, viewerID uint) (*Post, error) {
+	visible, args := user.PostsVisibleSQL("posts.user_id", viewerID)
+	var p Post
+	err := db.Joins("JOIN users u ON u.id = posts.user_id").
+		Where("posts.id = ? AND u.deactivated_at IS NULL AND "+visible, append([]interface{}{postID}, args...)...).
+		First(&p).Error
+	if err != nil {
+		return nil, err
+	}
+	return &p, nil
+}
+
+// findPostToInteract is findVisiblePost for liking and commenting. A block
+// between the viewer and the author, in either direction, is reported as
+// ErrBlocked.
+func findPostToInteract(db *gorm.DB, postID string, viewerID uint) (*Post, error) {
+	var author uint
+	if err := db.Model(&Post{}).Select("user_id").Where("id = ?", postID).Limit(1).Scan(&author).Error; err != nil {
+		return nil, err
+	}
+	if author != 0 {
+		blocked, err := user.IsBlocked(db, viewerID, author)
+		if err != nil {
+			return nil, err
+		}
+		if blocked {
+			return nil, ErrBlocked
+		}
+	}
+	return findVisiblePost(db, postID, viewerID)
+}
+
+func postLookupError(err error) error {
+	if errors.Is(err, ErrBlocked) {
+		return fiber.NewError(fiber.StatusForbidden, err.Error())
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return fiber.NewError(fiber.StatusNotFound, "post not found")
+	}
+	return fiber.NewError(fiber.StatusInternalServerError, "failed to find post")
+}
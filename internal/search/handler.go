@@ -3,19 +3,48 @@ package search
 import (
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
+	"unbound/internal/auth"
+	"unbound/internal/common/middleware"
+	"unbound/internal/user"
 )
 
 type SearchResult struct {
-	Type      string `json:"type"`
-	ID        uint   `json:"id"`
-	Content   string `json:"content"`
-	CreatedAt string `json:"created_at"`
+	Type      string         `json:"type"`
+	ID        uint           `json:"id"`
+	Content   string         `json:"content"`
+	CreatedAt string         `json:"created_at"`
+	User      *user.UserCard `json:"user,omitempty" gorm:"-"`
 }
 
-func RegisterSearchRoutes(app *fiber.App, db *gorm.DB) {
+// attachUserCards adds the profile card, as the viewer may see it, to every
+// user result.
+func attachUserCards(db *gorm.DB, results []SearchResult, viewerID uint) error {
+	var ids []uint
+	for _, r := range results {
+		if r.Type == "user" {
+			ids = append(ids, r.ID)
+		}
+	}
+	cards, err := user.LoadCards(db, ids, viewerID)
+	if err != nil {
+		return err
+	}
+	byID := make(map[uint]*user.UserCard, len(cards))
+	for i := range cards {
+		byID[cards[i].ID] = &cards[i]
+	}
+	for i := range results {
+		if results[i].Type == "user" {
+			results[i].User = byID[results[i].ID]
+		}
+	}
+	return nil
+}
+
+func RegisterSearchRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService) {
 	r := app.Group("/search")
 
-	r.Get("/", func(c *fiber.Ctx) error {
+	r.Get("/", middleware.OptionalAuth(authSvc), func(c *fiber.Ctx) error {
 		query := c.Query("query")
 		filterType := c.Query("type")
 		sortOrder := c.Query("sort")
@@ -26,16 +55,19 @@ func RegisterSearchRoutes(app *fiber.App, db *gorm.DB) {
 
 		var results []SearchResult
 		pattern := "%" + query + "%"
+		visible, visibleArgs := user.PostsVisibleSQL("user_id", user.ViewerID(c))
+		blocked, blockedArgs := user.BlockedSQL("users.id", user.ViewerID(c))
 
 		switch filterType {
 		case "user":
 			sql := `
 				SELECT 'user' AS type, id, username AS content, NULL AS created_at
 				FROM users
-				WHERE username ILIKE ?
+				WHERE username ILIKE ? AND deactivated_at IS NULL
+				AND NOT ` + blocked + `
 				LIMIT 50
 			`
-			if err := db.Raw(sql, pattern).Scan(&results).Error; err != nil {
+			if err := db.Raw(sql, append([]interface{}{pattern}, blockedArgs...)...).Scan(&results).Error; err != nil {
 				return fiber.NewError(fiber.StatusInternalServerError, "failed to search users")
 			}
 
@@ -49,25 +81,36 @@ func RegisterSearchRoutes(app *fiber.App, db *gorm.DB) {
 				SELECT 'post' AS type, id, content, created_at
 				FROM posts
 				WHERE content ILIKE ?
+				AND user_id NOT IN (SELECT id FROM users WHERE deactivated_at IS NOT NULL)
+				AND ` + visible + `
 				ORDER BY created_at ` + order + `
 				LIMIT 50
 			`
-			if err := db.Raw(sql, pattern).Scan(&results).Error; err != nil {
+			if err := db.Raw(sql, append([]interface{}{pattern}, visibleArgs...)...).Scan(&results).Error; err != nil {
 				return fiber.NewError(fiber.StatusInternalServerError, "failed to search posts")
 			}
 
 		default:
 			sql := `
-				SELECT 'user' AS type, id, username AS content, NULL AS created_at FROM users WHERE username ILIKE ?
+				SELECT 'user' AS type, id, username AS content, NULL AS created_at FROM users WHERE username ILIKE ? AND deactivated_at IS NULL
+					AND NOT ` + blocked + `
 				UNION
 				SELECT 'post' AS type, id, content, created_at FROM posts WHERE content ILIKE ?
+					AND user_id NOT IN (SELECT id FROM users WHERE deactivated_at IS NOT NULL)
+					AND ` + visible + `
 				LIMIT 50
 			`
-			if err := db.Raw(sql, pattern, pattern).Scan(&results).Error; err != nil {
+			args := append([]interface{}{pattern}, blockedArgs...)
+			args = append(args, pattern)
+			if err := db.Raw(sql, append(args, visibleArgs...)...).Scan(&results).Error; err != nil {
 				return fiber.NewError(fiber.StatusInternalServerError, "failed to perform search")
 			}
 		}
 
+		if err := attachUserCards(db, results, user.ViewerID(c)); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to perform search")
+		}
+
 		return c.JSON(results)
 	})
 }
@@ -0,0 +1,118 @@
+package user
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"unbound/internal/auth"
+	"unbound/internal/common/middleware"
+)
+
+// blockUser records the block and ends every follow and pending follow
+// request between the two accounts.
+func blockUser(db *gorm.DB, blockerID, blockedID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&Block{BlockerID: blockerID, BlockedID: blockedID}).Error; err != nil {
+			return err
+		}
+		pair := "(follower_id = ? AND following_id = ?) OR (follower_id = ? AND following_id = ?)"
+		if err := tx.Where(pair, blockerID, blockedID, blockedID, blockerID).Delete(&Follow{}).Error; err != nil {
+			return err
+		}
+		pair = "(requester_id = ? AND target_id = ?) OR (requester_id = ? AND target_id = ?)"
+		return tx.Where(pair, blockerID, blockedID, blockedID, blockerID).Delete(&FollowRequest{}).Error
+	})
+}
+
+func RegisterBlockRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService) {
+	r := app.Group("/users")
+	protected := middleware.JWTProtected(authSvc)
+	scope := middleware.RequireScope(auth.ScopeFollowsWrite)
+
+	// target resolves :username and refuses to act on the caller's own account.
+	target := func(c *fiber.Ctx) (uint, uint, error) {
+		userID := c.Locals("userID").(uint)
+		var u auth.User
+		if err := db.Scopes(auth.WhereUsername(c.Params("username"))).First(&u).Error; err != nil {
+			return 0, 0, fiber.NewError(fiber.StatusNotFound, "user not found")
+		}
+		if u.ID == userID {
+			return 0, 0, fiber.NewError(fiber.StatusBadRequest, "you can't do that to yourself")
+		}
+		return userID, u.ID, nil
+	}
+
+	r.Post("/:username/block", protected, scope, func(c *fiber.Ctx) error {
+		userID, targetID, err := target(c)
+		if err != nil {
+			return err
+		}
+		if err := blockUser(db, userID, targetID); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to block user")
+		}
+		return c.JSON(fiber.Map{"blocked": true})
+	})
+
+	r.Delete("/:username/block", protected, scope, func(c *fiber.Ctx) error {
+		userID, targetID, err := target(c)
+		if err != nil {
+			return err
+		}
+		if err := db.Where("blocker_id = ? AND blocked_id = ?", userID, targetID).Delete(&Block{}).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to unblock user")
+		}
+		return c.JSON(fiber.Map{"blocked": false})
+	})
+
+	r.Post("/:username/mute", protected, scope, func(c *fiber.Ctx) error {
+		userID, targetID, err := target(c)
+		if err != nil {
+			return err
+		}
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&Mute{MuterID: userID, MutedID: targetID}).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to mute user")
+		}
+		return c.JSON(fiber.Map{"muted": true})
+	})
+
+	r.Delete("/:username/mute", protected, scope, func(c *fiber.Ctx) error {
+		userID, targetID, err := target(c)
+		if err != nil {
+			return err
+		}
+		if err := db.Where("muter_id = ? AND muted_id = ?", userID, targetID).Delete(&Mute{}).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to unmute user")
+		}
+		return c.JSON(fiber.Map{"muted": false})
+	})
+
+	r.Get("/me/blocks", protected, scope, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		var ids []uint
+		if err := db.Model(&Block{}).Where("blocker_id = ?", userID).
+			Order("created_at DESC").Pluck("blocked_id", &ids).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch blocked users")
+		}
+		cards, err := LoadCards(db, ids, userID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch blocked users")
+		}
+		return c.JSON(fiber.Map{"success": true, "data": cards})
+	})
+
+	r.Get("/me/mutes", protected, scope, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		var ids []uint
+		if err := db.Model(&Mute{}).Where("muter_id = ?", userID).
+			Order("created_at DESC").Pluck("muted_id", &ids).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch muted users")
+		}
+		cards, err := LoadCards(db, ids, userID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch muted users")
+		}
+		return c.JSON(fiber.Map{"success": true, "data": cards})
+	})
+}
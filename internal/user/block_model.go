@@ -0,0 +1,20 @@
+package user
+
+import "time"
+
+// Block cuts all contact between two accounts: no follows, no chat, and
+// neither sees the other's posts, comments or notifications.
+type Block struct {
+	ID        uint `gorm:"primaryKey"`
+	BlockerID uint `gorm:"not null;uniqueIndex:idx_block_pair"`
+	BlockedID uint `gorm:"not null;uniqueIndex:idx_block_pair;index"`
+	CreatedAt time.Time
+}
+
+// Mute only hides the muted account's content from the muter.
+type Mute struct {
+	ID        uint `gorm:"primaryKey"`
+	MuterID   uint `gorm:"not null;uniqueIndex:idx_mute_pair"`
+	MutedID   uint `gorm:"not null;uniqueIndex:idx_mute_pair;index"`
+	CreatedAt time.Time
+}
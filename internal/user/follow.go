@@ -0,0 +1,96 @@
+package user
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"unbound/internal/notification"
+)
+
+var ErrFollowBlocked = errors.New("you can't follow this user")
+
+// FollowState is where a follow ends up after a PUT or DELETE.
+type FollowState struct {
+	Following bool `json:"following"`
+	Requested bool `json:"requested"`
+}
+
+// insertFollow creates the follow unless it already exists and reports
+// whether a row was actually written.
+func insertFollow(tx *gorm.DB, followerID, followingID uint) (bool, error) {
+	res := tx.Clauses(clause.OnConflict{
+		Columns:     []clause.Column{{Name: "follower_id"}, {Name: "following_id"}},
+		TargetWhere: clause.Where{Exprs: []clause.Expression{clause.Expr{SQL: "deleted_at IS NULL"}}},
+		DoNothing:   true,
+	}).Create(&Follow{FollowerID: followerID, FollowingID: followingID})
+	return res.RowsAffected > 0, res.Error
+}
+
+// followUser makes userID follow targetID, or ask to when the target is
+// private. Repeating it changes nothing, and the target is only notified
+// when a follow or request was really created.
+func followUser(db *gorm.DB, userID, targetID uint) (FollowState, error) {
+	var state FollowState
+	err := db.Transaction(func(tx *gorm.DB) error {
+		blocked, err := IsBlocked(tx, userID, targetID)
+		if err != nil {
+			return err
+		}
+		if blocked {
+			return ErrFollowBlocked
+		}
+
+		var following bool
+		if following, err = exists(tx, &Follow{}, "follower_id = ? AND following_id = ?", userID, targetID); err != nil {
+			return err
+		}
+		if following {
+			state.Following = true
+			return nil
+		}
+
+		private, err := isPrivate(tx, targetID)
+		if err != nil {
+			return err
+		}
+		if private {
+			state.Requested = true
+			res := tx.Clauses(clause.OnConflict{DoNothing: true}).
+				Create(&FollowRequest{RequesterID: userID, TargetID: targetID})
+			if res.Error != nil || res.RowsAffected == 0 {
+				return res.Error
+			}
+			return tx.Create(&notification.Notification{
+				UserID:  targetID,
+				ActorID: userID,
+				Type:    "follow_request",
+				Message: "Ada permintaan follow baru",
+			}).Error
+		}
+
+		state.Following = true
+		created, err := insertFollow(tx, userID, targetID)
+		if err != nil || !created {
+			return err
+		}
+		return tx.Create(&notification.Notification{
+			UserID:  targetID,
+			ActorID: userID,
+			Type:    "follow",
+			Message: "Kamu mendapatkan pengikut baru",
+		}).Error
+	})
+	return state, err
+}
+
+// unfollowUser ends the follow and withdraws any pending request. It is a
+// no-op when there is neither.
+func unfollowUser(db *gorm.DB, userID, targetID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("follower_id = ? AND following_id = ?", userID, targetID).Delete(&Follow{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("requester_id = ? AND target_id = ?", userID, targetID).Delete(&FollowRequest{}).Error
+	})
+}
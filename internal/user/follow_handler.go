@@ -1,113 +1,187 @@
 package user
 
 import (
-	"fmt"
-	"strings"
+	"errors"
+	"strconv"
+	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
 	"unbound/internal/auth"
 	"unbound/internal/common/middleware"
-	"unbound/internal/notification"
 )
 
 func RegisterFollowRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService) {
 	r := app.Group("/users")
 
-	r.Post("/:username/follow", middleware.JWTProtected(authSvc), func(c *fiber.Ctx) error {
-		targetUsername := c.Params("username")
+	// followTarget resolves :username for the follow endpoints.
+	followTarget := func(c *fiber.Ctx) (uint, uint, error) {
 		userID, ok := c.Locals("userID").(uint)
 		if !ok {
-			return fiber.NewError(fiber.StatusUnauthorized, "invalid user context")
+			return 0, 0, fiber.NewError(fiber.StatusUnauthorized, "invalid user context")
 		}
 
 		var target auth.User
-		if err := db.Where("username = ?", targetUsername).First(&target).Error; err != nil {
-			return fiber.NewError(fiber.StatusNotFound, "target user not found")
+		if err := db.Scopes(auth.WhereUsername(c.Params("username"))).Where("deactivated_at IS NULL").First(&target).Error; err != nil {
+			return 0, 0, fiber.NewError(fiber.StatusNotFound, "target user not found")
 		}
 
 		if userID == target.ID {
-			return fiber.NewError(fiber.StatusBadRequest, "you can't follow yourself")
+			return 0, 0, fiber.NewError(fiber.StatusBadRequest, "you can't follow yourself")
 		}
+		return userID, target.ID, nil
+	}
 
-		var existing Follow
-		if err := db.Where("follower_id = ? AND following_id = ?", userID, target.ID).
-			Limit(1).Find(&existing).Error; err == nil && existing.ID != 0 {
-
-			if err := db.Delete(&existing).Error; err != nil {
-				return fiber.NewError(fiber.StatusInternalServerError, "failed to unfollow")
-			}
-			return c.JSON(fiber.Map{"following": false})
+	r.Put("/:username/follow", middleware.JWTProtected(authSvc), middleware.RequireScope(auth.ScopeFollowsWrite), func(c *fiber.Ctx) error {
+		userID, targetID, err := followTarget(c)
+		if err != nil {
+			return err
 		}
 
-		newFollow := Follow{FollowerID: userID, FollowingID: target.ID}
-		if err := db.Create(&newFollow).Error; err != nil {
-			if strings.Contains(err.Error(), "unique") {
-				return c.JSON(fiber.Map{"following": true})
+		state, err := followUser(db, userID, targetID)
+		if err != nil {
+			if errors.Is(err, ErrFollowBlocked) {
+				return fiber.NewError(fiber.StatusForbidden, err.Error())
 			}
 			return fiber.NewError(fiber.StatusInternalServerError, "failed to follow")
 		}
+		return c.JSON(state)
+	})
 
-		if target.ID != userID {
-			notif := notification.Notification{
-				UserID:  target.ID,
-				ActorID: userID,
-				Type:    "follow",
-				Message: fmt.Sprintf("Kamu mendapatkan pengikut baru"),
-			}
-			db.Create(&notif)
+	r.Delete("/:username/follow", middleware.JWTProtected(authSvc), middleware.RequireScope(auth.ScopeFollowsWrite), func(c *fiber.Ctx) error {
+		userID, targetID, err := followTarget(c)
+		if err != nil {
+			return err
 		}
 
-		return c.JSON(fiber.Map{"following": true})
+		if err := unfollowUser(db, userID, targetID); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to unfollow")
+		}
+		return c.JSON(FollowState{})
 	})
 
-	r.Get("/:username/followers", func(c *fiber.Ctx) error {
-		username := c.Params("username")
+	r.Get("/:username/followers", middleware.OptionalAuth(authSvc), func(c *fiber.Ctx) error {
+		return listFollows(c, db, "following_id", "follower_id")
+	})
 
-		var target auth.User
-		if err := db.Where("username = ?", username).First(&target).Error; err != nil {
+	r.Get("/:username/following", middleware.OptionalAuth(authSvc), func(c *fiber.Ctx) error {
+		return listFollows(c, db, "follower_id", "following_id")
+	})
+
+	r.Get("/:username/relationship/:other", middleware.OptionalAuth(authSvc), func(c *fiber.Ctx) error {
+		var a, b auth.User
+		if err := db.Scopes(auth.WhereUsername(c.Params("username"))).Where("deactivated_at IS NULL").First(&a).Error; err != nil {
 			return fiber.NewError(fiber.StatusNotFound, "user not found")
 		}
-
-		var followers []struct {
-			Username string `json:"username"`
+		if err := db.Scopes(auth.WhereUsername(c.Params("other"))).Where("deactivated_at IS NULL").First(&b).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "user not found")
 		}
 
-		query := `
-			SELECT DISTINCT u.username
-			FROM follows f
-			JOIN users u ON u.id = f.follower_id
-			WHERE f.following_id = ?
-		`
-		if err := db.Raw(query, target.ID).Scan(&followers).Error; err != nil {
-			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch followers")
+		rel, err := loadRelationship(db, a.ID, b.ID, ViewerID(c) == a.ID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch relationship")
 		}
-
-		return c.JSON(followers)
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    rel,
+		})
 	})
+}
 
-	r.Get("/:username/following", func(c *fiber.Ctx) error {
-		username := c.Params("username")
-
-		var target auth.User
-		if err := db.Where("username = ?", username).First(&target).Error; err != nil {
-			return fiber.NewError(fiber.StatusNotFound, "user not found")
-		}
+type followListItem struct {
+	UserCard
+	FollowedAt time.Time `json:"followed_at"`
+	FollowsYou *bool     `json:"follows_you,omitempty"`
+	YouFollow  *bool     `json:"you_follow,omitempty"`
+	Mutual     *bool     `json:"mutual,omitempty"`
+}
 
-		var following []struct {
-			Username string `json:"username"`
+// listFollows pages through the follows of :username where ownCol is the
+// account itself and otherCol the accounts being listed, newest first. The
+// cursor is the id of the last follow on the previous page.
+func listFollows(c *fiber.Ctx, db *gorm.DB, ownCol, otherCol string) error {
+	var target auth.User
+	if err := db.Scopes(auth.WhereUsername(c.Params("username"))).Where("deactivated_at IS NULL").First(&target).Error; err != nil {
+		return fiber.NewError(fiber.StatusNotFound, "user not found")
+	}
+
+	limit, _ := strconv.Atoi(c.Query("limit", "20"))
+	if limit <= 0 || limit > 100 {
+		limit = 20
+	}
+	cursor, _ := strconv.ParseUint(c.Query("cursor"), 10, 64)
+	viewerID := ViewerID(c)
+	blocked, blockedArgs := BlockedSQL("u.id", viewerID)
+
+	base := db.Model(&Follow{}).
+		Joins("JOIN users u ON u.id = follows."+otherCol).
+		Where("follows."+ownCol+" = ? AND u.deactivated_at IS NULL AND u.deleted_at IS NULL", target.ID).
+		Where("NOT "+blocked, blockedArgs...)
+
+	var total int64
+	if err := base.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch follows")
+	}
+
+	var rows []struct {
+		ID        uint
+		UserID    uint
+		CreatedAt time.Time
+	}
+	page := base.Session(&gorm.Session{})
+	if cursor > 0 {
+		page = page.Where("follows.id < ?", cursor)
+	}
+	if err := page.Select("follows.id, follows." + otherCol + " AS user_id, follows.created_at").
+		Order("follows.id DESC").Limit(limit + 1).Scan(&rows).Error; err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch follows")
+	}
+
+	var nextCursor *uint
+	if len(rows) > limit {
+		rows = rows[:limit]
+		nextCursor = &rows[limit-1].ID
+	}
+
+	ids := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.UserID)
+	}
+	cards, err := LoadCards(db, ids, viewerID)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch follows")
+	}
+	youFollow, followsYou, err := followFlags(db, viewerID, ids)
+	if err != nil {
+		return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch follows")
+	}
+
+	byID := make(map[uint]UserCard, len(cards))
+	for _, card := range cards {
+		byID[card.ID] = card
+	}
+	items := make([]followListItem, 0, len(rows))
+	for _, row := range rows {
+		card, ok := byID[row.UserID]
+		if !ok {
+			continue
 		}
-
-		query := `
-			SELECT DISTINCT u.username
-			FROM follows f
-			JOIN users u ON u.id = f.following_id
-			WHERE f.follower_id = ?
-		`
-		if err := db.Raw(query, target.ID).Scan(&following).Error; err != nil {
-			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch following")
+		item := followListItem{UserCard: card, FollowedAt: row.CreatedAt}
+		if viewerID != 0 {
+			fy, yf := followsYou[row.UserID], youFollow[row.UserID]
+			mutual := fy && yf
+			item.FollowsYou, item.YouFollow, item.Mutual = &fy, &yf, &mutual
 		}
-
-		return c.JSON(following)
+		items = append(items, item)
+	}
+
+	return c.JSON(fiber.Map{
+		"success": true,
+		"data":    items,
+		"meta": fiber.Map{
+			"total":       total,
+			"limit":       limit,
+			"next_cursor": nextCursor,
+		},
 	})
 }
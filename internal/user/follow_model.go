@@ -1,9 +1,30 @@
 package user
 
-import "gorm.io/gorm"
+import (
+	"log"
 
+	"gorm.io/gorm"
+)
+
+// Follow rows are soft-deleted, so the pair is only unique among live rows.
 type Follow struct {
 	gorm.Model
-	FollowerID  uint `gorm:"not null"`
-	FollowingID uint `gorm:"not null"`
+	FollowerID  uint `gorm:"not null;uniqueIndex:idx_follow_pair,where:deleted_at IS NULL"`
+	FollowingID uint `gorm:"not null;uniqueIndex:idx_follow_pair,where:deleted_at IS NULL"`
+}
+
+// DedupeFollows removes duplicate live follows left by the old toggle
+// endpoint, keeping the oldest row of each pair, so the unique index can be
+// created. It must run before AutoMigrate.
+func DedupeFollows(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&Follow{}) || db.Migrator().HasIndex(&Follow{}, "idx_follow_pair") {
+		return nil
+	}
+	res := db.Exec(`DELETE FROM follows a USING follows b
+		WHERE a.follower_id = b.follower_id AND a.following_id = b.following_id
+			AND a.deleted_at IS NULL AND b.deleted_at IS NULL AND a.id > b.id`)
+	if res.Error == nil && res.RowsAffected > 0 {
+		log.Printf("✅ Removed %d duplicate follows", res.RowsAffected)
+	}
+	return res.Error
 }
@@ -0,0 +1,167 @@
+package user
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"unbound/internal/auth"
+	"unbound/internal/common/middleware"
+	"unbound/internal/notification"
+)
+
+func isPrivate(db *gorm.DB, userID uint) (bool, error) {
+	var private bool
+	err := db.Model(&Profile{}).Select("is_private").Where("user_id = ?", userID).
+		Limit(1).Scan(&private).Error
+	return private, err
+}
+
+// approveFollowRequest turns the request into a follow and tells the
+// requester.
+func approveFollowRequest(tx *gorm.DB, req FollowRequest) error {
+	if err := tx.Delete(&req).Error; err != nil {
+		return err
+	}
+
+	if _, err := insertFollow(tx, req.RequesterID, req.TargetID); err != nil {
+		return err
+	}
+
+	return tx.Create(&notification.Notification{
+		UserID:  req.RequesterID,
+		ActorID: req.TargetID,
+		Type:    "follow_accept",
+		Message: "Permintaan follow kamu diterima",
+	}).Error
+}
+
+// approveAllFollowRequests is used when a private account goes public.
+func approveAllFollowRequests(db *gorm.DB, userID uint) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var reqs []FollowRequest
+		if err := tx.Where("target_id = ?", userID).Find(&reqs).Error; err != nil {
+			return err
+		}
+		for _, req := range reqs {
+			if err := approveFollowRequest(tx, req); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+func RegisterFollowRequestRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService) {
+	r := app.Group("/users/me/follow-requests", middleware.JWTProtected(authSvc), middleware.RequireScope(auth.ScopeFollowsWrite))
+
+	r.Get("/", func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+
+		var reqs []FollowRequest
+		if err := db.Where("target_id = ?", userID).Order("created_at DESC").Find(&reqs).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch follow requests")
+		}
+
+		ids := make([]uint, 0, len(reqs))
+		for _, req := range reqs {
+			ids = append(ids, req.RequesterID)
+		}
+		cards, err := LoadCards(db, ids, userID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch follow requests")
+		}
+		byID := make(map[uint]UserCard, len(cards))
+		for _, card := range cards {
+			byID[card.ID] = card
+		}
+
+		type requestResp struct {
+			ID        uint      `json:"id"`
+			User      UserCard  `json:"user"`
+			CreatedAt time.Time `json:"created_at"`
+		}
+		resp := make([]requestResp, 0, len(reqs))
+		for _, req := range reqs {
+			card, ok := byID[req.RequesterID]
+			if !ok {
+				continue
+			}
+			resp = append(resp, requestResp{ID: req.ID, User: card, CreatedAt: req.CreatedAt})
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    resp,
+		})
+	})
+
+	find := func(c *fiber.Ctx, tx *gorm.DB) (FollowRequest, error) {
+		var req FollowRequest
+		id, err := strconv.ParseUint(c.Params("id"), 10, 64)
+		if err != nil {
+			return req, fiber.NewError(fiber.StatusBadRequest, "invalid request id")
+		}
+		if err := tx.Where("id = ? AND target_id = ?", id, c.Locals("userID").(uint)).First(&req).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return req, fiber.NewError(fiber.StatusNotFound, "follow request not found")
+			}
+			return req, fiber.NewError(fiber.StatusInternalServerError, "failed to fetch follow request")
+		}
+		return req, nil
+	}
+
+	r.Post("/:id/approve", func(c *fiber.Ctx) error {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			req, err := find(c, tx)
+			if err != nil {
+				return err
+			}
+			return approveFollowRequest(tx, req)
+		})
+		if err != nil {
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				return fe
+			}
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to approve follow request")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Follow request approved",
+		})
+	})
+
+	r.Post("/:id/reject", func(c *fiber.Ctx) error {
+		err := db.Transaction(func(tx *gorm.DB) error {
+			req, err := find(c, tx)
+			if err != nil {
+				return err
+			}
+			if err := tx.Delete(&req).Error; err != nil {
+				return err
+			}
+			return tx.Create(&notification.Notification{
+				UserID:  req.RequesterID,
+				ActorID: req.TargetID,
+				Type:    "follow_reject",
+				Message: "Permintaan follow kamu ditolak",
+			}).Error
+		})
+		if err != nil {
+			var fe *fiber.Error
+			if errors.As(err, &fe) {
+				return fe
+			}
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to reject follow request")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Follow request rejected",
+		})
+	})
+}
@@ -0,0 +1,12 @@
+package user
+
+import "time"
+
+// FollowRequest is a pending follow of a private account, waiting for the
+// owner to approve or reject it.
+type FollowRequest struct {
+	ID          uint `gorm:"primaryKey"`
+	RequesterID uint `gorm:"not null;uniqueIndex:idx_follow_request_pair"`
+	TargetID    uint `gorm:"not null;uniqueIndex:idx_follow_request_pair;index"`
+	CreatedAt   time.Time
+}
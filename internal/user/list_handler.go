@@ -0,0 +1,273 @@
+package user
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"unbound/internal/auth"
+	"unbound/internal/common/middleware"
+)
+
+const (
+	maxListsPerUser = 100
+	maxListMembers  = 500
+)
+
+type listSummary struct {
+	List
+	MembersCount int64 `json:"members_count"`
+}
+
+// FindList returns the list with the given id if viewerID may see it. A
+// private list looks the same as a missing one to everybody but its owner.
+func FindList(db *gorm.DB, id string, viewerID uint) (*List, error) {
+	listID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, gorm.ErrRecordNotFound
+	}
+	var list List
+	if err := db.First(&list, listID).Error; err != nil {
+		return nil, err
+	}
+	if list.IsPrivate && list.OwnerID != viewerID {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return &list, nil
+}
+
+type listReq struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+	IsPrivate   *bool   `json:"is_private"`
+}
+
+func (req listReq) apply(l *List) error {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if name == "" || utf8.RuneCountInString(name) > 50 || strings.ContainsAny(name, "\r\n\t") {
+			return fiber.NewError(fiber.StatusBadRequest, "name must be a single line of 1-50 characters")
+		}
+		l.Name = name
+	}
+	if req.Description != nil {
+		desc := strings.TrimSpace(*req.Description)
+		if utf8.RuneCountInString(desc) > 160 {
+			return fiber.NewError(fiber.StatusBadRequest, "description is too long")
+		}
+		l.Description = desc
+	}
+	if req.IsPrivate != nil {
+		l.IsPrivate = *req.IsPrivate
+	}
+	return nil
+}
+
+func RegisterListRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService) {
+	r := app.Group("/lists")
+	protected := middleware.JWTProtected(authSvc)
+	scope := middleware.RequireScope(auth.ScopeFollowsWrite)
+
+	// ownList resolves :id to a list the caller owns.
+	ownList := func(c *fiber.Ctx) (*List, error) {
+		userID := c.Locals("userID").(uint)
+		list, err := FindList(db, c.Params("id"), userID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, fiber.NewError(fiber.StatusNotFound, "list not found")
+			}
+			return nil, fiber.NewError(fiber.StatusInternalServerError, "failed to fetch list")
+		}
+		if list.OwnerID != userID {
+			return nil, fiber.NewError(fiber.StatusForbidden, "you can only change your own lists")
+		}
+		return list, nil
+	}
+
+	// member resolves :username for the member endpoints.
+	member := func(c *fiber.Ctx) (uint, error) {
+		var u auth.User
+		if err := db.Scopes(auth.WhereUsername(c.Params("username"))).Where("deactivated_at IS NULL").First(&u).Error; err != nil {
+			return 0, fiber.NewError(fiber.StatusNotFound, "user not found")
+		}
+		return u.ID, nil
+	}
+
+	r.Post("/", protected, scope, func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+
+		var req listReq
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid body")
+		}
+		if req.Name == nil {
+			return fiber.NewError(fiber.StatusBadRequest, "name is required")
+		}
+		list := List{OwnerID: userID}
+		if err := req.apply(&list); err != nil {
+			return err
+		}
+
+		var count int64
+		if err := db.Model(&List{}).Where("owner_id = ?", userID).Count(&count).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to create list")
+		}
+		if count >= maxListsPerUser {
+			return fiber.NewError(fiber.StatusBadRequest, "you have too many lists")
+		}
+
+		if err := db.Create(&list).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to create list")
+		}
+		return c.Status(fiber.StatusCreated).JSON(fiber.Map{
+			"success": true,
+			"data":    list,
+		})
+	})
+
+	r.Get("/:id", middleware.OptionalAuth(authSvc), func(c *fiber.Ctx) error {
+		viewerID := ViewerID(c)
+		list, err := FindList(db, c.Params("id"), viewerID)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return fiber.NewError(fiber.StatusNotFound, "list not found")
+			}
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch list")
+		}
+
+		var ids []uint
+		if err := db.Model(&ListMember{}).Where("list_id = ?", list.ID).
+			Order("created_at DESC").Pluck("user_id", &ids).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch list")
+		}
+		cards, err := LoadCards(db, ids, viewerID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch list")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data": fiber.Map{
+				"list":    listSummary{List: *list, MembersCount: int64(len(cards))},
+				"members": cards,
+			},
+		})
+	})
+
+	r.Patch("/:id", protected, scope, func(c *fiber.Ctx) error {
+		list, err := ownList(c)
+		if err != nil {
+			return err
+		}
+
+		var req listReq
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid body")
+		}
+		if err := req.apply(list); err != nil {
+			return err
+		}
+		if err := db.Save(list).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to update list")
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    list,
+		})
+	})
+
+	r.Delete("/:id", protected, scope, func(c *fiber.Ctx) error {
+		list, err := ownList(c)
+		if err != nil {
+			return err
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Where("list_id = ?", list.ID).Delete(&ListMember{}).Error; err != nil {
+				return err
+			}
+			return tx.Delete(list).Error
+		})
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to delete list")
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "List deleted",
+		})
+	})
+
+	r.Put("/:id/members/:username", protected, scope, func(c *fiber.Ctx) error {
+		list, err := ownList(c)
+		if err != nil {
+			return err
+		}
+		memberID, err := member(c)
+		if err != nil {
+			return err
+		}
+
+		blocked, err := IsBlocked(db, list.OwnerID, memberID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to add list member")
+		}
+		if blocked {
+			return fiber.NewError(fiber.StatusForbidden, "you can't add this user")
+		}
+
+		var count int64
+		if err := db.Model(&ListMember{}).Where("list_id = ?", list.ID).Count(&count).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to add list member")
+		}
+		if count >= maxListMembers {
+			return fiber.NewError(fiber.StatusBadRequest, "list is full")
+		}
+
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).
+			Create(&ListMember{ListID: list.ID, UserID: memberID}).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to add list member")
+		}
+		return c.JSON(fiber.Map{"member": true})
+	})
+
+	r.Delete("/:id/members/:username", protected, scope, func(c *fiber.Ctx) error {
+		list, err := ownList(c)
+		if err != nil {
+			return err
+		}
+		memberID, err := member(c)
+		if err != nil {
+			return err
+		}
+
+		if err := db.Where("list_id = ? AND user_id = ?", list.ID, memberID).Delete(&ListMember{}).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to remove list member")
+		}
+		return c.JSON(fiber.Map{"member": false})
+	})
+
+	app.Get("/users/:username/lists", middleware.OptionalAuth(authSvc), func(c *fiber.Ctx) error {
+		var owner auth.User
+		if err := db.Scopes(auth.WhereUsername(c.Params("username"))).Where("deactivated_at IS NULL").First(&owner).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "user not found")
+		}
+
+		q := db.Model(&List{}).Where("owner_id = ?", owner.ID)
+		if ViewerID(c) != owner.ID {
+			q = q.Where("is_private = ?", false)
+		}
+		var lists []listSummary
+		if err := q.Select("lists.*, (SELECT COUNT(*) FROM list_members lm WHERE lm.list_id = lists.id) AS members_count").
+			Order("created_at DESC").Scan(&lists).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch lists")
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    lists,
+		})
+	})
+}
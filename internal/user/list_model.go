@@ -0,0 +1,22 @@
+package user
+
+import "time"
+
+// List is a curated set of accounts whose posts can be read as a separate
+// timeline. Private lists are only visible to their owner.
+type List struct {
+	ID          uint      `gorm:"primaryKey" json:"id"`
+	OwnerID     uint      `gorm:"not null;index" json:"owner_id"`
+	Name        string    `gorm:"size:50;not null" json:"name"`
+	Description string    `gorm:"size:160" json:"description"`
+	IsPrivate   bool      `gorm:"not null;default:false" json:"is_private"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+type ListMember struct {
+	ID        uint `gorm:"primaryKey"`
+	ListID    uint `gorm:"not null;uniqueIndex:idx_list_member_pair"`
+	UserID    uint `gorm:"not null;uniqueIndex:idx_list_member_pair;index"`
+	CreatedAt time.Time
+}
@@ -0,0 +1,139 @@
+package user
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+)
+
+// Profile fields whose visibility the owner controls. The id, username and
+// the counts are always public.
+const (
+	FieldEmail       = "email"
+	FieldDisplayName = "display_name"
+	FieldBio         = "bio"
+	FieldWebsite     = "website"
+	FieldLocation    = "location"
+	FieldPronouns    = "pronouns"
+	FieldAvatar      = "avatar_url"
+	FieldBanner      = "banner_url"
+	FieldJoinedAt    = "joined_at"
+)
+
+var privacyFields = map[string]bool{
+	FieldEmail: true, FieldDisplayName: true, FieldBio: true, FieldWebsite: true,
+	FieldLocation: true, FieldPronouns: true, FieldAvatar: true, FieldBanner: true,
+	FieldJoinedAt: true,
+}
+
+// defaultPublicFields applies to accounts that never changed their privacy
+// settings: everything except the email address.
+const defaultPublicFields = "avatar_url banner_url bio display_name joined_at location pronouns website"
+
+// ViewerID returns the caller authenticated by OptionalAuth, or 0 for an
+// anonymous request.
+func ViewerID(c *fiber.Ctx) uint {
+	id, _ := c.Locals("userID").(uint)
+	return id
+}
+
+func normalizePublicFields(fields []string) (string, error) {
+	seen := map[string]bool{}
+	out := make([]string, 0, len(fields))
+	for _, f := range fields {
+		f = strings.TrimSpace(f)
+		if !privacyFields[f] {
+			return "", fmt.Errorf("unknown profile field %q", f)
+		}
+		if !seen[f] {
+			seen[f] = true
+			out = append(out, f)
+		}
+	}
+	sort.Strings(out)
+	return strings.Join(out, " "), nil
+}
+
+// visibleFields is the set of fields the viewer may see on the owner's
+// profile. A nil result means everything, for the owner themselves.
+func visibleFields(publicFields string, ownerID, viewerID uint) map[string]bool {
+	if viewerID != 0 && viewerID == ownerID {
+		return nil
+	}
+	visible := map[string]bool{}
+	for _, f := range strings.Fields(publicFields) {
+		visible[f] = true
+	}
+	return visible
+}
+
+// hide clears the string fields the viewer may not see.
+func hide(visible map[string]bool, fields map[string]*string) {
+	if visible == nil {
+		return
+	}
+	for name, v := range fields {
+		if !visible[name] {
+			*v = ""
+		}
+	}
+}
+
+// UserCard is the short form of a profile used in lists and search results.
+type UserCard struct {
+	ID             uint   `json:"id"`
+	Username       string `json:"username"`
+	DisplayName    string `json:"display_name,omitempty"`
+	Bio            string `json:"bio,omitempty"`
+	AvatarURL      string `json:"avatar_url,omitempty"`
+	AvatarThumbURL string `json:"avatar_thumb_url,omitempty"`
+}
+
+// LoadCards returns the cards of the given accounts in the same order, as
+// seen by viewerID (0 for anonymous). Deactivated accounts are left out.
+func LoadCards(db *gorm.DB, ids []uint, viewerID uint) ([]UserCard, error) {
+	cards := make([]UserCard, 0, len(ids))
+	if len(ids) == 0 {
+		return cards, nil
+	}
+
+	var rows []struct {
+		UserCard
+		PublicFields string
+	}
+	if err := db.Raw(`
+		SELECT u.id, u.username,
+			COALESCE(p.display_name, '') AS display_name,
+			COALESCE(p.bio, '') AS bio,
+			COALESCE(p.avatar_url, '') AS avatar_url,
+			COALESCE(p.avatar_thumb_url, '') AS avatar_thumb_url,
+			COALESCE(p.public_fields, ?) AS public_fields
+		FROM users u
+		LEFT JOIN profiles p ON p.user_id = u.id
+		WHERE u.id IN ? AND u.deactivated_at IS NULL AND u.deleted_at IS NULL
+	`, defaultPublicFields, ids).Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	byID := make(map[uint]UserCard, len(rows))
+	for _, r := range rows {
+		card := r.UserCard
+		visible := visibleFields(r.PublicFields, card.ID, viewerID)
+		hide(visible, map[string]*string{
+			FieldDisplayName: &card.DisplayName,
+			FieldBio:         &card.Bio,
+			FieldAvatar:      &card.AvatarURL,
+		})
+		hide(visible, map[string]*string{FieldAvatar: &card.AvatarThumbURL})
+		byID[card.ID] = card
+	}
+	for _, id := range ids {
+		if card, ok := byID[id]; ok {
+			cards = append(cards, card)
+		}
+	}
+	return cards, nil
+}
@@ -1,15 +1,40 @@
 package user
 
 import (
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
 	"github.com/gofiber/fiber/v2"
 	"gorm.io/gorm"
+	"unbound/internal/auth"
+	"unbound/internal/common/middleware"
+	"unbound/internal/common/storage"
 )
 
+// ProfileResponse leaves out the fields the viewer is not allowed to see.
+// PublicFields is only filled in for the owner.
 type ProfileResponse struct {
-	ID       uint        `json:"id"`
-	Username string      `json:"username"`
-	Email    string      `json:"email"`
-	Posts    []UserPost  `json:"posts"`
+	ID             uint       `json:"id"`
+	Username       string     `json:"username"`
+	Email          string     `json:"email,omitempty"`
+	DisplayName    string     `json:"display_name,omitempty"`
+	Bio            string     `json:"bio,omitempty"`
+	Website        string     `json:"website,omitempty"`
+	Location       string     `json:"location,omitempty"`
+	Pronouns       string     `json:"pronouns,omitempty"`
+	AvatarURL      string     `json:"avatar_url,omitempty"`
+	AvatarThumbURL string     `json:"avatar_thumb_url,omitempty"`
+	BannerURL      string     `json:"banner_url,omitempty"`
+	BannerThumbURL string     `json:"banner_thumb_url,omitempty"`
+	JoinedAt       *time.Time `json:"joined_at,omitempty"`
+	FollowersCount int64      `json:"followers_count"`
+	FollowingCount int64      `json:"following_count"`
+	PostsCount     int64      `json:"posts_count"`
+	IsPrivate      bool       `json:"is_private"`
+	PublicFields   []string   `json:"public_fields,omitempty"`
+	Posts          []UserPost `json:"posts,omitempty"`
 }
 
 type UserPost struct {
@@ -18,47 +43,284 @@ type UserPost struct {
 	CreatedAt string `json:"created_at"`
 }
 
-func RegisterProfileRoutes(app *fiber.App, db *gorm.DB) {
+// UpdateProfileReq only touches the fields that are present; an empty string
+// clears a field.
+type UpdateProfileReq struct {
+	DisplayName *string `json:"display_name"`
+	Bio         *string `json:"bio"`
+	Website     *string `json:"website"`
+	Location    *string `json:"location"`
+	Pronouns    *string `json:"pronouns"`
+	AvatarURL   *string `json:"avatar_url"`
+	BannerURL   *string `json:"banner_url"`
+	// PublicFields replaces the list of fields other users may see.
+	PublicFields *[]string `json:"public_fields"`
+	IsPrivate    *bool     `json:"is_private"`
+}
+
+func (req UpdateProfileReq) apply(p *Profile) error {
+	text := []struct {
+		name  string
+		value *string
+		max   int
+		dst   *string
+	}{
+		{"display_name", req.DisplayName, 50, &p.DisplayName},
+		{"bio", req.Bio, 160, &p.Bio},
+		{"location", req.Location, 30, &p.Location},
+		{"pronouns", req.Pronouns, 30, &p.Pronouns},
+	}
+	for _, f := range text {
+		if f.value == nil {
+			continue
+		}
+		v := strings.TrimSpace(*f.value)
+		if utf8.RuneCountInString(v) > f.max {
+			return fiber.NewError(fiber.StatusBadRequest, f.name+" is too long")
+		}
+		if strings.ContainsAny(v, "\r\n\t") && f.name != "bio" {
+			return fiber.NewError(fiber.StatusBadRequest, f.name+" must be a single line")
+		}
+		*f.dst = v
+	}
+
+	links := []struct {
+		name  string
+		value *string
+		max   int
+		dst   *string
+	}{
+		{"website", req.Website, 100, &p.Website},
+		{"avatar_url", req.AvatarURL, 512, &p.AvatarURL},
+		{"banner_url", req.BannerURL, 512, &p.BannerURL},
+	}
+	for _, f := range links {
+		if f.value == nil {
+			continue
+		}
+		v := strings.TrimSpace(*f.value)
+		if v != "" {
+			u, err := url.Parse(v)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fiber.NewError(fiber.StatusBadRequest, f.name+" must be an http(s) URL")
+			}
+		}
+		if len(v) > f.max {
+			return fiber.NewError(fiber.StatusBadRequest, f.name+" is too long")
+		}
+		*f.dst = v
+	}
+
+	// A link replaces any uploaded image.
+	if req.AvatarURL != nil {
+		p.AvatarThumbURL, p.AvatarKeys = "", ""
+	}
+	if req.BannerURL != nil {
+		p.BannerThumbURL, p.BannerKeys = "", ""
+	}
+
+	if req.PublicFields != nil {
+		fields, err := normalizePublicFields(*req.PublicFields)
+		if err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, err.Error())
+		}
+		p.PublicFields = &fields
+	}
+	if req.IsPrivate != nil {
+		p.IsPrivate = *req.IsPrivate
+	}
+	return nil
+}
+
+type profileRow struct {
+	ID             uint
+	Username       string
+	Email          string
+	JoinedAt       time.Time
+	PublicFields   string
+	DisplayName    string
+	Bio            string
+	Website        string
+	Location       string
+	Pronouns       string
+	AvatarURL      string
+	AvatarThumbURL string
+	BannerURL      string
+	BannerThumbURL string
+	FollowersCount int64
+	FollowingCount int64
+	PostsCount     int64
+	IsPrivate      bool
+}
+
+// loadProfile returns the profile of an account as seen by viewerID (0 for
+// anonymous), without posts.
+func loadProfile(db *gorm.DB, userID, viewerID uint) (*ProfileResponse, error) {
+	var row profileRow
+	err := db.Raw(`
+		SELECT
+			u.id, u.username, u.email, u.created_at AS joined_at,
+			COALESCE(p.public_fields, ?) AS public_fields,
+			COALESCE(p.display_name, '') AS display_name,
+			COALESCE(p.bio, '') AS bio,
+			COALESCE(p.website, '') AS website,
+			COALESCE(p.location, '') AS location,
+			COALESCE(p.pronouns, '') AS pronouns,
+			COALESCE(p.avatar_url, '') AS avatar_url,
+			COALESCE(p.avatar_thumb_url, '') AS avatar_thumb_url,
+			COALESCE(p.banner_url, '') AS banner_url,
+			COALESCE(p.banner_thumb_url, '') AS banner_thumb_url,
+			COALESCE(p.is_private, false) AS is_private,
+			(SELECT COUNT(*) FROM follows f JOIN users fu ON fu.id = f.follower_id
+				WHERE f.following_id = u.id AND f.deleted_at IS NULL AND fu.deactivated_at IS NULL) AS followers_count,
+			(SELECT COUNT(*) FROM follows f JOIN users fu ON fu.id = f.following_id
+				WHERE f.follower_id = u.id AND f.deleted_at IS NULL AND fu.deactivated_at IS NULL) AS following_count,
+			(SELECT COUNT(*) FROM posts WHERE user_id = u.id AND deleted_at IS NULL) AS posts_count
+		FROM users u
+		LEFT JOIN profiles p ON p.user_id = u.id
+		WHERE u.id = ?
+	`, defaultPublicFields, userID).Scan(&row).Error
+	if err != nil {
+		return nil, err
+	}
+	if row.ID == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+
+	resp := ProfileResponse{
+		ID:             row.ID,
+		Username:       row.Username,
+		Email:          row.Email,
+		DisplayName:    row.DisplayName,
+		Bio:            row.Bio,
+		Website:        row.Website,
+		Location:       row.Location,
+		Pronouns:       row.Pronouns,
+		AvatarURL:      row.AvatarURL,
+		AvatarThumbURL: row.AvatarThumbURL,
+		BannerURL:      row.BannerURL,
+		BannerThumbURL: row.BannerThumbURL,
+		FollowersCount: row.FollowersCount,
+		FollowingCount: row.FollowingCount,
+		PostsCount:     row.PostsCount,
+		IsPrivate:      row.IsPrivate,
+	}
+	visible := visibleFields(row.PublicFields, userID, viewerID)
+	hide(visible, map[string]*string{
+		FieldEmail:       &resp.Email,
+		FieldDisplayName: &resp.DisplayName,
+		FieldBio:         &resp.Bio,
+		FieldWebsite:     &resp.Website,
+		FieldLocation:    &resp.Location,
+		FieldPronouns:    &resp.Pronouns,
+		FieldAvatar:      &resp.AvatarURL,
+		FieldBanner:      &resp.BannerURL,
+	})
+	hide(visible, map[string]*string{FieldAvatar: &resp.AvatarThumbURL, FieldBanner: &resp.BannerThumbURL})
+	if visible == nil || visible[FieldJoinedAt] {
+		resp.JoinedAt = &row.JoinedAt
+	}
+	if visible == nil {
+		resp.PublicFields = strings.Fields(row.PublicFields)
+	}
+	return &resp, nil
+}
+
+func RegisterProfileRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService, store storage.BlobStore) {
 	r := app.Group("/users")
+	registerImageRoutes(r, db, authSvc, store)
+
+	r.Patch("/me", middleware.SessionProtected(authSvc), func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+
+		var req UpdateProfileReq
+		if err := c.BodyParser(&req); err != nil {
+			return fiber.NewError(fiber.StatusBadRequest, "invalid body")
+		}
+
+		defaults := defaultPublicFields
+		var p Profile
+		if err := db.Where(Profile{UserID: userID}).Attrs(Profile{PublicFields: &defaults}).
+			FirstOrInit(&p).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch profile")
+		}
+		wasPrivate := p.IsPrivate
+		oldAvatar, oldBanner := p.AvatarKeys, p.BannerKeys
+		if err := req.apply(&p); err != nil {
+			return err
+		}
+		if err := db.Save(&p).Error; err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to update profile")
+		}
+		if p.AvatarKeys != oldAvatar {
+			deleteBlobs(store, oldAvatar)
+		}
+		if p.BannerKeys != oldBanner {
+			deleteBlobs(store, oldBanner)
+		}
+		if wasPrivate && !p.IsPrivate {
+			if err := approveAllFollowRequests(db, userID); err != nil {
+				return fiber.NewError(fiber.StatusInternalServerError, "failed to approve pending follow requests")
+			}
+		}
 
-	r.Get("/:username", func(c *fiber.Ctx) error {
+		resp, err := loadProfile(db, userID, userID)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch profile")
+		}
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    resp,
+		})
+	})
+
+	r.Get("/:username", middleware.OptionalAuth(authSvc), func(c *fiber.Ctx) error {
 		username := c.Params("username")
 
 		var user struct {
-			ID       uint
-			Username string
-			Email    string
+			ID uint
 		}
-		if err := db.Raw(`
-			SELECT id, username, email
-			FROM users
-			WHERE username = ?
-			LIMIT 1
-		`, username).Scan(&user).Error; err != nil {
+		if err := db.Model(&auth.User{}).
+			Select("id").
+			Scopes(auth.WhereUsername(username)).
+			Where("deactivated_at IS NULL").
+			Limit(1).Scan(&user).Error; err != nil {
 			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch user")
 		}
 
 		if user.ID == 0 {
+			// Renamed accounts keep answering on their old username.
+			var current string
+			if err := db.Raw(`
+				SELECT u.username
+				FROM username_histories h
+				JOIN users u ON u.id = h.user_id
+				WHERE h.old_username = ? AND u.deactivated_at IS NULL AND u.deleted_at IS NULL
+				LIMIT 1
+			`, auth.CanonicalUsername(username)).Scan(&current).Error; err != nil {
+				return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch user")
+			}
+			if current != "" {
+				return c.Redirect("/users/"+url.PathEscape(current), fiber.StatusMovedPermanently)
+			}
 			return fiber.NewError(fiber.StatusNotFound, "user not found")
 		}
 
-		var posts []UserPost
+		resp, err := loadProfile(db, user.ID, ViewerID(c))
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch user")
+		}
+
+		visible, args := PostsVisibleSQL("user_id", ViewerID(c))
 		if err := db.Raw(`
 			SELECT id, content, created_at
 			FROM posts
-			WHERE user_id = ?
+			WHERE user_id = ? AND `+visible+`
 			ORDER BY created_at DESC
-		`, user.ID).Scan(&posts).Error; err != nil {
+		`, append([]interface{}{user.ID}, args...)...).Scan(&resp.Posts).Error; err != nil {
 			return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch posts")
 		}
 
-		resp := ProfileResponse{
-			ID:       user.ID,
-			Username: user.Username,
-			Email:    user.Email,
-			Posts:    posts,
-		}
-
 		return c.JSON(resp)
 	})
 }
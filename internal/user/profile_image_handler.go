@@ -0,0 +1,191 @@
+package user
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"unbound/internal/auth"
+	"unbound/internal/common/imaging"
+	"unbound/internal/common/middleware"
+	"unbound/internal/common/storage"
+)
+
+// maxImageUpload must stay below the server's BodyLimit.
+const maxImageUpload = 5 << 20
+
+type imageVariant struct {
+	name          string
+	width, height int
+}
+
+// profileImage describes one of the two uploadable profile pictures. The
+// medium variant becomes the main URL, the thumbnail is for lists and cards.
+type profileImage struct {
+	kind     string
+	variants []imageVariant
+	// fields returns the URL, thumbnail URL and keys columns of p.
+	fields func(p *Profile) (url, thumb, keys *string)
+}
+
+var (
+	avatarImage = profileImage{
+		kind:     "avatar",
+		variants: []imageVariant{{"thumb", 96, 96}, {"medium", 400, 400}},
+		fields: func(p *Profile) (*string, *string, *string) {
+			return &p.AvatarURL, &p.AvatarThumbURL, &p.AvatarKeys
+		},
+	}
+	bannerImage = profileImage{
+		kind:     "banner",
+		variants: []imageVariant{{"thumb", 600, 200}, {"medium", 1500, 500}},
+		fields: func(p *Profile) (*string, *string, *string) {
+			return &p.BannerURL, &p.BannerThumbURL, &p.BannerKeys
+		},
+	}
+)
+
+// deleteBlobs removes stored files that are no longer referenced. Failures
+// only leave an orphaned file behind, so they are logged and ignored.
+func deleteBlobs(store storage.BlobStore, keys string) {
+	for _, key := range strings.Fields(keys) {
+		if err := store.Delete(key); err != nil {
+			log.Printf("⚠️ failed to delete %s: %v", key, err)
+		}
+	}
+}
+
+// storeImage processes an upload into the variants of img and stores them.
+// It returns the URLs by variant name and the keys written.
+func storeImage(store storage.BlobStore, userID uint, img profileImage, data []byte) (map[string]string, []string, error) {
+	decoded, err := imaging.Decode(data)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return nil, nil, err
+	}
+	prefix := fmt.Sprintf("%ss/%d/%s", img.kind, userID, hex.EncodeToString(id[:]))
+
+	urls := map[string]string{}
+	var keys []string
+	for _, v := range img.variants {
+		out, err := imaging.Encode(decoded.Fill(v.width, v.height))
+		if err == nil {
+			key := prefix + "_" + v.name + "." + out.Ext
+			if err = store.Put(key, out.ContentType, out.Data); err == nil {
+				keys = append(keys, key)
+				urls[v.name] = store.URL(key)
+				continue
+			}
+		}
+		deleteBlobs(store, strings.Join(keys, " "))
+		return nil, nil, err
+	}
+	return urls, keys, nil
+}
+
+func imageError(err error) error {
+	switch {
+	case errors.Is(err, imaging.ErrUnsupportedFormat):
+		return fiber.NewError(fiber.StatusUnsupportedMediaType, err.Error())
+	case errors.Is(err, imaging.ErrTooLarge), errors.Is(err, imaging.ErrInvalidImage):
+		return fiber.NewError(fiber.StatusBadRequest, err.Error())
+	}
+	return fiber.NewError(fiber.StatusInternalServerError, "failed to store image")
+}
+
+func registerImageRoutes(r fiber.Router, db *gorm.DB, authSvc *auth.AuthService, store storage.BlobStore) {
+	protected := middleware.SessionProtected(authSvc)
+
+	for _, img := range []profileImage{avatarImage, bannerImage} {
+		img := img
+
+		// The upload is a multipart form with the file in the "image" field.
+		r.Put("/me/"+img.kind, protected, func(c *fiber.Ctx) error {
+			userID := c.Locals("userID").(uint)
+
+			fh, err := c.FormFile("image")
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "image file is required")
+			}
+			if fh.Size > maxImageUpload {
+				return fiber.NewError(fiber.StatusRequestEntityTooLarge, "image must be at most 5 MB")
+			}
+			f, err := fh.Open()
+			if err != nil {
+				return fiber.NewError(fiber.StatusBadRequest, "failed to read image")
+			}
+			data, err := io.ReadAll(io.LimitReader(f, maxImageUpload+1))
+			f.Close()
+			if err != nil || len(data) > maxImageUpload {
+				return fiber.NewError(fiber.StatusBadRequest, "failed to read image")
+			}
+
+			urls, keys, err := storeImage(store, userID, img, data)
+			if err != nil {
+				return imageError(err)
+			}
+
+			defaults := defaultPublicFields
+			var p Profile
+			if err := db.Where(Profile{UserID: userID}).Attrs(Profile{PublicFields: &defaults}).
+				FirstOrInit(&p).Error; err != nil {
+				deleteBlobs(store, strings.Join(keys, " "))
+				return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch profile")
+			}
+			url, thumb, stored := img.fields(&p)
+			oldKeys := *stored
+			*url, *thumb, *stored = urls["medium"], urls["thumb"], strings.Join(keys, " ")
+			if err := db.Save(&p).Error; err != nil {
+				deleteBlobs(store, *stored)
+				return fiber.NewError(fiber.StatusInternalServerError, "failed to update profile")
+			}
+			deleteBlobs(store, oldKeys)
+
+			resp, err := loadProfile(db, userID, userID)
+			if err != nil {
+				return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch profile")
+			}
+			return c.JSON(fiber.Map{
+				"success": true,
+				"data":    resp,
+			})
+		})
+
+		r.Delete("/me/"+img.kind, protected, func(c *fiber.Ctx) error {
+			userID := c.Locals("userID").(uint)
+
+			var p Profile
+			if err := db.Where("user_id = ?", userID).Limit(1).Find(&p).Error; err != nil {
+				return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch profile")
+			}
+			if p.UserID != 0 {
+				url, thumb, stored := img.fields(&p)
+				oldKeys := *stored
+				*url, *thumb, *stored = "", "", ""
+				if err := db.Save(&p).Error; err != nil {
+					return fiber.NewError(fiber.StatusInternalServerError, "failed to update profile")
+				}
+				deleteBlobs(store, oldKeys)
+			}
+
+			resp, err := loadProfile(db, userID, userID)
+			if err != nil {
+				return fiber.NewError(fiber.StatusInternalServerError, "failed to fetch profile")
+			}
+			return c.JSON(fiber.Map{
+				"success": true,
+				"data":    resp,
+			})
+		})
+	}
+}
@@ -0,0 +1,29 @@
+package user
+
+import "time"
+
+// Profile holds the public-facing details of an account. Accounts that never
+// edited their profile have no row.
+type Profile struct {
+	UserID      uint   `gorm:"primaryKey;autoIncrement:false"`
+	DisplayName string `gorm:"size:50"`
+	Bio         string `gorm:"size:160"`
+	Website     string `gorm:"size:100"`
+	Location    string `gorm:"size:30"`
+	Pronouns    string `gorm:"size:30"`
+	AvatarURL   string `gorm:"size:512"`
+	BannerURL   string `gorm:"size:512"`
+	// Uploaded images also get a thumbnail, and remember their storage keys
+	// (space separated) so they can be deleted when replaced.
+	AvatarThumbURL string `gorm:"size:512"`
+	BannerThumbURL string `gorm:"size:512"`
+	AvatarKeys     string `gorm:"size:255"`
+	BannerKeys     string `gorm:"size:255"`
+	// IsPrivate accounts approve their followers, and only followers see
+	// their posts.
+	IsPrivate bool `gorm:"not null;default:false"`
+	// PublicFields lists the fields other users may see, space separated.
+	// NULL means the defaults.
+	PublicFields *string `gorm:"size:255"`
+	UpdatedAt    time.Time
+}
@@ -0,0 +1,81 @@
+package user
+
+import (
+	"gorm.io/gorm"
+)
+
+// Relationship describes how account A relates to account B. The pending
+// request, block and mute flags are private to A and only filled in when A
+// is the one asking.
+type Relationship struct {
+	SourceID   uint  `json:"source_id"`
+	TargetID   uint  `json:"target_id"`
+	Following  bool  `json:"following"`
+	FollowedBy bool  `json:"followed_by"`
+	Mutual     bool  `json:"mutual"`
+	Requested  *bool `json:"requested,omitempty"`
+	Blocking   *bool `json:"blocking,omitempty"`
+	Muting     *bool `json:"muting,omitempty"`
+}
+
+func exists(db *gorm.DB, model interface{}, query string, args ...interface{}) (bool, error) {
+	var count int64
+	err := db.Model(model).Where(query, args...).Limit(1).Count(&count).Error
+	return count > 0, err
+}
+
+func loadRelationship(db *gorm.DB, a, b uint, private bool) (*Relationship, error) {
+	rel := &Relationship{SourceID: a, TargetID: b}
+	var err error
+	if rel.Following, err = exists(db, &Follow{}, "follower_id = ? AND following_id = ?", a, b); err != nil {
+		return nil, err
+	}
+	if rel.FollowedBy, err = exists(db, &Follow{}, "follower_id = ? AND following_id = ?", b, a); err != nil {
+		return nil, err
+	}
+	rel.Mutual = rel.Following && rel.FollowedBy
+	if !private {
+		return rel, nil
+	}
+
+	requested, err := exists(db, &FollowRequest{}, "requester_id = ? AND target_id = ?", a, b)
+	if err != nil {
+		return nil, err
+	}
+	blocking, err := exists(db, &Block{}, "blocker_id = ? AND blocked_id = ?", a, b)
+	if err != nil {
+		return nil, err
+	}
+	muting, err := exists(db, &Mute{}, "muter_id = ? AND muted_id = ?", a, b)
+	if err != nil {
+		return nil, err
+	}
+	rel.Requested, rel.Blocking, rel.Muting = &requested, &blocking, &muting
+	return rel, nil
+}
+
+// followFlags returns, among ids, the accounts the viewer follows and the
+// accounts that follow the viewer.
+func followFlags(db *gorm.DB, viewerID uint, ids []uint) (youFollow, followsYou map[uint]bool, err error) {
+	youFollow, followsYou = map[uint]bool{}, map[uint]bool{}
+	if viewerID == 0 || len(ids) == 0 {
+		return youFollow, followsYou, nil
+	}
+
+	var out, in []uint
+	if err := db.Model(&Follow{}).Where("follower_id = ? AND following_id IN ?", viewerID, ids).
+		Pluck("following_id", &out).Error; err != nil {
+		return nil, nil, err
+	}
+	if err := db.Model(&Follow{}).Where("following_id = ? AND follower_id IN ?", viewerID, ids).
+		Pluck("follower_id", &in).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, id := range out {
+		youFollow[id] = true
+	}
+	for _, id := range in {
+		followsYou[id] = true
+	}
+	return youFollow, followsYou, nil
+}
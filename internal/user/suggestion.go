@@ -0,0 +1,224 @@
+package user
+
+import (
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	suggestionTTL       = 15 * time.Minute
+	suggestionPoolSize  = 100
+	popularAccountsSize = 200
+)
+
+type Suggestion struct {
+	UserCard
+	MutualFollows  int64   `json:"mutual_follows"`
+	Interactions   int64   `json:"interactions"`
+	FollowersCount int64   `json:"followers_count"`
+	Reason         string  `json:"reason"`
+	Score          float64 `json:"-"`
+}
+
+type suggestionEntry struct {
+	items     []Suggestion
+	expiresAt time.Time
+}
+
+// SuggestionService ranks accounts to follow. Scoring walks the follow graph
+// and the like/comment history, so each user's ranked pool is cached for
+// suggestionTTL; only the cheap exclusion check runs on every request.
+type SuggestionService struct {
+	DB *gorm.DB
+
+	mu        sync.Mutex
+	cache     map[uint]suggestionEntry
+	lastSweep time.Time
+}
+
+func NewSuggestionService(db *gorm.DB) *SuggestionService {
+	return &SuggestionService{DB: db, cache: make(map[uint]suggestionEntry)}
+}
+
+// For returns up to limit suggestions for the user, best first.
+func (s *SuggestionService) For(userID uint, limit int) ([]Suggestion, error) {
+	pool, err := s.pool(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uint, 0, len(pool))
+	for _, sug := range pool {
+		ids = append(ids, sug.ID)
+	}
+	excluded, err := s.excluded(userID, ids)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make([]Suggestion, 0, limit)
+	for _, sug := range pool {
+		if excluded[sug.ID] {
+			continue
+		}
+		out = append(out, sug)
+		if len(out) == limit {
+			break
+		}
+	}
+
+	cardIDs := make([]uint, 0, len(out))
+	for _, sug := range out {
+		cardIDs = append(cardIDs, sug.ID)
+	}
+	cards, err := LoadCards(s.DB, cardIDs, userID)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]UserCard, len(cards))
+	for _, card := range cards {
+		byID[card.ID] = card
+	}
+	result := make([]Suggestion, 0, len(out))
+	for _, sug := range out {
+		if card, ok := byID[sug.ID]; ok {
+			sug.UserCard = card
+			result = append(result, sug)
+		}
+	}
+	return result, nil
+}
+
+func (s *SuggestionService) Dismiss(userID, dismissedID uint) error {
+	return s.DB.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&SuggestionDismissal{UserID: userID, DismissedID: dismissedID}).Error
+}
+
+func (s *SuggestionService) pool(userID uint) ([]Suggestion, error) {
+	now := time.Now()
+	s.mu.Lock()
+	entry, ok := s.cache[userID]
+	s.mu.Unlock()
+	if ok && now.Before(entry.expiresAt) {
+		return entry.items, nil
+	}
+
+	items, err := s.rank(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) > suggestionTTL {
+		for id, e := range s.cache {
+			if now.After(e.expiresAt) {
+				delete(s.cache, id)
+			}
+		}
+		s.lastSweep = now
+	}
+	s.cache[userID] = suggestionEntry{items: items, expiresAt: now.Add(suggestionTTL)}
+	s.mu.Unlock()
+	return items, nil
+}
+
+// rank scores candidates by how many of the user's follows follow them,
+// by likes and comments exchanged with the user, and by overall
+// popularity, which is what new users with an empty graph get.
+func (s *SuggestionService) rank(userID uint) ([]Suggestion, error) {
+	var rows []Suggestion
+	err := s.DB.Raw(`
+		WITH my_follows AS (
+			SELECT following_id AS id FROM follows WHERE follower_id = @user AND deleted_at IS NULL
+		),
+		fof AS (
+			SELECT f.following_id AS id, COUNT(DISTINCT f.follower_id) AS n
+			FROM follows f JOIN my_follows m ON m.id = f.follower_id
+			WHERE f.deleted_at IS NULL
+			GROUP BY f.following_id
+		),
+		interactions AS (
+			SELECT id, SUM(n) AS n FROM (
+				SELECT p.user_id AS id, COUNT(*) AS n FROM likes l JOIN posts p ON p.id = l.post_id
+					WHERE l.user_id = @user AND l.deleted_at IS NULL GROUP BY p.user_id
+				UNION ALL
+				SELECT p.user_id, COUNT(*) FROM comments c JOIN posts p ON p.id = c.post_id
+					WHERE c.user_id = @user AND c.deleted_at IS NULL GROUP BY p.user_id
+				UNION ALL
+				SELECT l.user_id, COUNT(*) FROM likes l JOIN posts p ON p.id = l.post_id
+					WHERE p.user_id = @user AND l.deleted_at IS NULL GROUP BY l.user_id
+				UNION ALL
+				SELECT c.user_id, COUNT(*) FROM comments c JOIN posts p ON p.id = c.post_id
+					WHERE p.user_id = @user AND c.deleted_at IS NULL GROUP BY c.user_id
+			) x GROUP BY id
+		),
+		popular AS (
+			SELECT following_id AS id, COUNT(*) AS n FROM follows
+			WHERE deleted_at IS NULL
+			GROUP BY following_id ORDER BY n DESC LIMIT @popular
+		)
+		SELECT u.id,
+			COALESCE(fof.n, 0) AS mutual_follows,
+			COALESCE(i.n, 0) AS interactions,
+			COALESCE(pop.n, 0) AS followers_count,
+			3 * COALESCE(fof.n, 0) + 2 * COALESCE(i.n, 0) + LN(1 + COALESCE(pop.n, 0)) AS score
+		FROM users u
+		LEFT JOIN fof ON fof.id = u.id
+		LEFT JOIN interactions i ON i.id = u.id
+		LEFT JOIN popular pop ON pop.id = u.id
+		WHERE (fof.id IS NOT NULL OR i.id IS NOT NULL OR pop.id IS NOT NULL)
+			AND u.id <> @user AND u.deactivated_at IS NULL AND u.deleted_at IS NULL
+			AND u.id NOT IN (SELECT id FROM my_follows)
+		ORDER BY score DESC, u.id ASC
+		LIMIT @pool
+	`, map[string]interface{}{
+		"user":    userID,
+		"popular": popularAccountsSize,
+		"pool":    suggestionPoolSize,
+	}).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range rows {
+		switch {
+		case rows[i].MutualFollows > 0:
+			rows[i].Reason = "followed_by_people_you_follow"
+		case rows[i].Interactions > 0:
+			rows[i].Reason = "interacted_with_you"
+		default:
+			rows[i].Reason = "popular"
+		}
+	}
+	return rows, nil
+}
+
+// excluded returns the candidates that must not be suggested right now:
+// followed, requested, blocked either way, muted or dismissed. It runs on
+// every request so the cached pool never shows stale entries.
+func (s *SuggestionService) excluded(userID uint, ids []uint) (map[uint]bool, error) {
+	out := map[uint]bool{}
+	if len(ids) == 0 {
+		return out, nil
+	}
+
+	var rows []uint
+	err := s.DB.Raw(`
+		SELECT following_id FROM follows WHERE follower_id = @user AND following_id IN @ids AND deleted_at IS NULL
+		UNION SELECT target_id FROM follow_requests WHERE requester_id = @user AND target_id IN @ids
+		UNION SELECT blocked_id FROM blocks WHERE blocker_id = @user AND blocked_id IN @ids
+		UNION SELECT blocker_id FROM blocks WHERE blocked_id = @user AND blocker_id IN @ids
+		UNION SELECT muted_id FROM mutes WHERE muter_id = @user AND muted_id IN @ids
+		UNION SELECT dismissed_id FROM suggestion_dismissals WHERE user_id = @user AND dismissed_id IN @ids
+	`, map[string]interface{}{"user": userID, "ids": ids}).Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range rows {
+		out[id] = true
+	}
+	return out, nil
+}
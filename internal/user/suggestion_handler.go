@@ -0,0 +1,51 @@
+package user
+
+import (
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"gorm.io/gorm"
+	"unbound/internal/auth"
+	"unbound/internal/common/middleware"
+)
+
+func RegisterSuggestionRoutes(app *fiber.App, db *gorm.DB, authSvc *auth.AuthService) {
+	svc := NewSuggestionService(db)
+	r := app.Group("/users/me/suggestions", middleware.JWTProtected(authSvc))
+
+	r.Get("/", middleware.RequireScope(auth.ScopePostsRead), func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+		limit, _ := strconv.Atoi(c.Query("limit", "20"))
+		if limit <= 0 || limit > 50 {
+			limit = 20
+		}
+
+		suggestions, err := svc.For(userID, limit)
+		if err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to load suggestions")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"data":    suggestions,
+		})
+	})
+
+	r.Post("/:username/dismiss", middleware.RequireScope(auth.ScopeFollowsWrite), func(c *fiber.Ctx) error {
+		userID := c.Locals("userID").(uint)
+
+		var target auth.User
+		if err := db.Scopes(auth.WhereUsername(c.Params("username"))).First(&target).Error; err != nil {
+			return fiber.NewError(fiber.StatusNotFound, "user not found")
+		}
+
+		if err := svc.Dismiss(userID, target.ID); err != nil {
+			return fiber.NewError(fiber.StatusInternalServerError, "failed to dismiss suggestion")
+		}
+
+		return c.JSON(fiber.Map{
+			"success": true,
+			"message": "Suggestion dismissed",
+		})
+	})
+}
@@ -0,0 +1,11 @@
+package user
+
+import "time"
+
+// SuggestionDismissal hides an account from the user's suggestions for good.
+type SuggestionDismissal struct {
+	ID          uint `gorm:"primaryKey"`
+	UserID      uint `gorm:"not null;uniqueIndex:idx_suggestion_dismissal_pair"`
+	DismissedID uint `gorm:"not null;uniqueIndex:idx_suggestion_dismissal_pair"`
+	CreatedAt   time.Time
+}
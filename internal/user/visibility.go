@@ -0,0 +1,43 @@
+package user
+
+import "gorm.io/gorm"
+
+// BlockedSQL returns a condition for raw queries that holds when the user
+// whose id is in userCol and viewerID blocked each other in either direction.
+func BlockedSQL(userCol string, viewerID uint) (string, []interface{}) {
+	sql := `EXISTS (SELECT 1 FROM blocks bv WHERE (bv.blocker_id = ? AND bv.blocked_id = ` + userCol + `)
+			OR (bv.blocker_id = ` + userCol + ` AND bv.blocked_id = ?))`
+	return sql, []interface{}{viewerID, viewerID}
+}
+
+// HiddenSQL returns a condition for raw queries that holds when content by
+// the user whose id is in authorCol must be hidden from viewerID: either of
+// them blocked the other, or the viewer muted the author.
+func HiddenSQL(authorCol string, viewerID uint) (string, []interface{}) {
+	blocked, args := BlockedSQL(authorCol, viewerID)
+	sql := `(` + blocked + `
+		OR EXISTS (SELECT 1 FROM mutes mv WHERE mv.muter_id = ? AND mv.muted_id = ` + authorCol + `))`
+	return sql, append(args, viewerID)
+}
+
+// PostsVisibleSQL returns a condition for raw queries that holds when
+// viewerID (0 for anonymous) may see the posts of the user whose id is in
+// authorCol: the author is the viewer, or has a public account or is
+// followed by the viewer, and is neither blocked nor muted.
+func PostsVisibleSQL(authorCol string, viewerID uint) (string, []interface{}) {
+	hidden, hiddenArgs := HiddenSQL(authorCol, viewerID)
+	sql := `(` + authorCol + ` = ? OR (
+		(NOT EXISTS (SELECT 1 FROM profiles pv WHERE pv.user_id = ` + authorCol + ` AND pv.is_private)
+			OR EXISTS (SELECT 1 FROM follows fv WHERE fv.following_id = ` + authorCol + ` AND fv.follower_id = ? AND fv.deleted_at IS NULL))
+		AND NOT ` + hidden + `))`
+	return sql, append([]interface{}{viewerID, viewerID}, hiddenArgs...)
+}
+
+// IsBlocked reports whether either user blocked the other.
+func IsBlocked(db *gorm.DB, a, b uint) (bool, error) {
+	var count int64
+	err := db.Model(&Block{}).
+		Where("(blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)", a, b, b, a).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -0,0 +1,139 @@
+package user
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// testDB opens the database in TEST_DATABASE_URL inside a transaction that
+// is rolled back afterwards, so the tests leave nothing behind.
+func testDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tx := db.Begin()
+	if tx.Error != nil {
+		t.Fatal(tx.Error)
+	}
+	t.Cleanup(func() { tx.Rollback() })
+	if err := tx.AutoMigrate(&Profile{}, &Follow{}, &Block{}, &Mute{}); err != nil {
+		t.Fatal(err)
+	}
+	// Rows left by others would change what is visible.
+	for _, table := range []string{"profiles", "follows", "blocks", "mutes"} {
+		if err := tx.Exec("DELETE FROM " + table).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return tx
+}
+
+func TestPostsVisibleSQL(t *testing.T) {
+	db := testDB(t)
+
+	// 1 is the viewer and has a private account.
+	// 2 is public.                    3 is private and followed by 1.
+	// 4 is private.                   5 is private, 1 unfollowed it.
+	// 6 is public, blocked by 1.      7 is public and blocked 1.
+	// 8 is public, muted by 1.        9 is private, followed and muted by 1.
+	// 2 also blocked 4.
+	for _, id := range []uint{1, 3, 4, 5, 9} {
+		must(t, db.Create(&Profile{UserID: id, IsPrivate: true}).Error)
+	}
+	for _, id := range []uint{2, 6, 7, 8} {
+		must(t, db.Create(&Profile{UserID: id}).Error)
+	}
+	for _, id := range []uint{3, 5, 9} {
+		must(t, db.Create(&Follow{FollowerID: 1, FollowingID: id}).Error)
+	}
+	must(t, db.Where("follower_id = 1 AND following_id = 5").Delete(&Follow{}).Error)
+	must(t, db.Create(&Block{BlockerID: 1, BlockedID: 6}).Error)
+	must(t, db.Create(&Block{BlockerID: 7, BlockedID: 1}).Error)
+	must(t, db.Create(&Block{BlockerID: 2, BlockedID: 4}).Error)
+	must(t, db.Create(&Mute{MuterID: 1, MutedID: 8}).Error)
+	must(t, db.Create(&Mute{MuterID: 1, MutedID: 9}).Error)
+
+	tests := []struct {
+		name   string
+		viewer uint
+		want   []uint
+	}{
+		{"follower with blocks and mutes", 1, []uint{1, 2, 3}},
+		{"anonymous", 0, []uint{2, 6, 7, 8}},
+		{"blocked by a public account", 4, []uint{4, 6, 7, 8}},
+		{"blocked by a private account", 6, []uint{2, 6, 7, 8}},
+	}
+	for _, tt := range tests {
+		visible, args := PostsVisibleSQL("a.id", tt.viewer)
+		var got []uint
+		err := db.Raw(`SELECT a.id FROM generate_series(1, 9) AS a(id) WHERE `+visible+` ORDER BY a.id`, args...).
+			Scan(&got).Error
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: visible authors = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestIsBlocked(t *testing.T) {
+	db := testDB(t)
+	must(t, db.Create(&Block{BlockerID: 1, BlockedID: 2}).Error)
+
+	tests := []struct {
+		a, b uint
+		want bool
+	}{
+		{1, 2, true},
+		{2, 1, true},
+		{1, 3, false},
+		{3, 2, false},
+	}
+	for _, tt := range tests {
+		got, err := IsBlocked(db, tt.a, tt.b)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != tt.want {
+			t.Errorf("IsBlocked(%d, %d) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+}
+
+func TestBlockedSQL(t *testing.T) {
+	db := testDB(t)
+	// 1 blocked 2, 3 blocked 1, 4 is only muted by 1.
+	must(t, db.Create(&Block{BlockerID: 1, BlockedID: 2}).Error)
+	must(t, db.Create(&Block{BlockerID: 3, BlockedID: 1}).Error)
+	must(t, db.Create(&Mute{MuterID: 1, MutedID: 4}).Error)
+
+	blocked, args := BlockedSQL("a.id", 1)
+	var got []uint
+	err := db.Raw(`SELECT a.id FROM generate_series(1, 4) AS a(id) WHERE NOT `+blocked+` ORDER BY a.id`, args...).
+		Scan(&got).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []uint{1, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("unblocked users = %v, want %v", got, want)
+	}
+}
+
+func must(t *testing.T, err error) {
+	t.Helper()
+	if err != nil {
+		t.Fatal(err)
+	}
+}